@@ -28,7 +28,7 @@ var countCommand = command.NewExecutableCommand("count", "Count files matching t
 		}
 		fmt.Printf("Counting files in folder: %s with pattern: %s\n", path, pattern)
 
-		fsMatcher := glob.FSMatcher(pattern)
+		fsMatcher := glob.FSMatcher(pattern).WithTypes(glob.TypeAll &^ glob.TypeDir)
 		count := 0
 		start := time.Now()
 		err := fsMatcher.WalkDirFS(path, func(path string, entry fs.DirEntry) error {
@@ -48,6 +48,7 @@ var countCommand = command.NewExecutableCommand("count", "Count files matching t
 
 func init() {
 	rootCommand.AddChild(countCommand)
+	rootCommand.AddChild(mvCommand)
 }
 
 func main() {
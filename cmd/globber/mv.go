@@ -0,0 +1,308 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"github.com/azuyamat/gear/command"
+	"github.com/azuyamat/globber/glob"
+)
+
+var mvCommand = command.NewExecutableCommand("mv", "Move files matching a glob pattern to paths built from a template").
+	Args(
+		command.NewStringArg("path", "Root directory"),
+		command.NewStringArg("from", "Glob pattern selecting the files to move"),
+		command.NewStringArg("to", "Target template filled from the pattern's * and ** wildcards"),
+	).
+	Flags(
+		command.NewBoolFlag("dry-run", "n", "Print the planned moves without applying them", false),
+	).
+	Handler(func(ctx *command.Context, args command.ValidatedArgs) error {
+		return runMv(args.String("path"), args.String("from"), args.String("to"), args.FlagBool("dry-run"), os.Stdout)
+	})
+
+// rename is os.Rename, replaced in tests to make moves fail.
+var rename = os.Rename
+
+// runMv plans the moves below root, prints them to w and applies them unless
+// dryRun is set.
+func runMv(root, from, to string, dryRun bool, w io.Writer) error {
+	moves, err := planMoves(root, from, to)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range moves {
+		fmt.Fprintf(w, "%s -> %s\n", m.from, m.to)
+	}
+	if dryRun {
+		fmt.Fprintf(w, "Planned %s, nothing changed\n", plural(len(moves), "move"))
+		return nil
+	}
+	if err := applyMoves(root, moves, w); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Moved %s\n", plural(len(moves), "file"))
+	return nil
+}
+
+// plural formats n with noun, adding an s unless n is one.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// move is a planned rename. Paths are slash-separated and relative to the
+// root.
+type move struct {
+	from, to string
+}
+
+// planMoves matches from below root and rewrites every matching file to the
+// template to. It fails without touching disk if two files would end up at
+// the same path, if a target lies below another target, if a target already
+// exists and is not itself moved away, or if a directory a target needs is a
+// file that stays in place.
+func planMoves(root, from, to string) ([]move, error) {
+	rewriter, err := glob.NewRewriter(from, to)
+	if err != nil {
+		return nil, err
+	}
+	fm, err := glob.CompileFS(from)
+	if err != nil {
+		return nil, err
+	}
+
+	var moves []move
+	var conflicts []error
+	targets := make(map[string]string)
+	err = fm.WithTypes(glob.TypeAll&^glob.TypeDir).
+		WithSortedOutput(true).
+		WalkDirFS(root, func(path string, entry fs.DirEntry) error {
+			target, _ := rewriter.Rewrite(path)
+			if !fs.ValidPath(target) || target == "." {
+				conflicts = append(conflicts, fmt.Errorf("%s: target %q is not a valid relative path", path, target))
+				return nil
+			}
+			if other, ok := targets[target]; ok {
+				conflicts = append(conflicts, fmt.Errorf("%s and %s would both move to %s", other, path, target))
+				return nil
+			}
+			targets[target] = path
+			if target != path {
+				moves = append(moves, move{from: path, to: target})
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	movedAway := make(map[string]bool, len(moves))
+	for _, m := range moves {
+		movedAway[m.from] = true
+	}
+moves:
+	for _, m := range moves {
+		dirs := parentDirs(m.to)
+		for _, dir := range dirs {
+			if other, ok := targets[dir]; ok {
+				conflicts = append(conflicts, fmt.Errorf("%s would move to %s, below %s where %s moves", m.from, m.to, dir, other))
+				continue moves
+			}
+		}
+		// Walk down the target's directories until one is missing or moved
+		// away; nothing below it can exist.
+		for _, dir := range dirs {
+			if movedAway[dir] {
+				continue moves
+			}
+			info, err := os.Stat(osPath(root, dir))
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				continue moves
+			case err != nil:
+				return nil, err
+			case !info.IsDir():
+				conflicts = append(conflicts, fmt.Errorf("%s: cannot move to %s because %s is not a directory", m.from, m.to, dir))
+				continue moves
+			}
+		}
+		if movedAway[m.to] {
+			continue
+		}
+		_, err := os.Lstat(osPath(root, m.to))
+		switch {
+		case err == nil:
+			conflicts = append(conflicts, fmt.Errorf("%s: target %s already exists", m.from, m.to))
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, err
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return nil, errors.Join(conflicts...)
+	}
+	return moves, nil
+}
+
+// journalName is the file under the root that records a move in progress.
+// It lists every planned move with its temporary name, followed by each step
+// as it completes, so that files can be put back by hand if the process dies
+// between the two phases. It is removed once the moves succeed or are rolled
+// back.
+const journalName = ".globber-mv-journal"
+
+// step is an applied change that rollback can undo: a rename from one path to
+// another, or a directory created at to.
+type step struct {
+	from, to string
+	mkdir    bool
+}
+
+func (s step) String() string {
+	if s.mkdir {
+		return fmt.Sprintf("mkdir %q", s.to)
+	}
+	return fmt.Sprintf("rename %q %q", s.from, s.to)
+}
+
+// applyMoves renames in two phases so that moves may swap or chain names:
+// every source is first renamed to a temporary name beside it, then every
+// temporary file to its target. The plan and completed steps are written to
+// the journal, and if a step fails they are undone in reverse to restore the
+// original tree. Rollback problems are reported to w.
+func applyMoves(root string, moves []move, w io.Writer) error {
+	journalPath := filepath.Join(root, journalName)
+	journal, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s exists, so an earlier mv may have been interrupted; restore the files it lists and remove it", journalPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	var log []step
+	record := func(s step) error {
+		log = append(log, s)
+		_, err := fmt.Fprintln(journal, s)
+		return err
+	}
+	rollback := func(cause error) error {
+		for i := len(log) - 1; i >= 0; i-- {
+			s := log[i]
+			var err error
+			if s.mkdir {
+				err = os.Remove(s.to)
+			} else {
+				err = rename(s.to, s.from)
+			}
+			if err != nil {
+				journal.Close()
+				fmt.Fprintln(w, "Rollback incomplete, remaining steps:")
+				for j := i; j >= 0; j-- {
+					if log[j].mkdir {
+						fmt.Fprintf(w, "  rmdir %s\n", log[j].to)
+					} else {
+						fmt.Fprintf(w, "  mv %s %s\n", log[j].to, log[j].from)
+					}
+				}
+				return fmt.Errorf("%w (rollback failed: %v; journal kept at %s)", cause, err, journalPath)
+			}
+		}
+		journal.Close()
+		if err := os.Remove(journalPath); err != nil {
+			return fmt.Errorf("%w (all changes rolled back, but %v)", cause, err)
+		}
+		return fmt.Errorf("%w (all changes rolled back)", cause)
+	}
+
+	temps := make([]string, len(moves))
+	for i, m := range moves {
+		src := osPath(root, m.from)
+		temps[i] = filepath.Join(filepath.Dir(src), fmt.Sprintf(".globber-mv-%d-%d-%s", os.Getpid(), i, filepath.Base(src)))
+		if _, err := fmt.Fprintf(journal, "plan %q %q %q\n", src, temps[i], osPath(root, m.to)); err != nil {
+			return rollback(err)
+		}
+	}
+
+	for i, m := range moves {
+		if _, err := os.Lstat(temps[i]); err == nil {
+			return rollback(fmt.Errorf("temporary file %s already exists", temps[i]))
+		}
+		src := osPath(root, m.from)
+		if err := rename(src, temps[i]); err != nil {
+			return rollback(err)
+		}
+		if err := record(step{from: src, to: temps[i]}); err != nil {
+			return rollback(err)
+		}
+	}
+
+	for i, m := range moves {
+		dst := osPath(root, m.to)
+		created, err := mkdirAll(filepath.Dir(dst))
+		for _, dir := range created {
+			if err := record(step{to: dir, mkdir: true}); err != nil {
+				return rollback(err)
+			}
+		}
+		if err != nil {
+			return rollback(err)
+		}
+		// rename replaces existing files, so recheck what was planned.
+		if _, err := os.Lstat(dst); err == nil {
+			return rollback(fmt.Errorf("target %s appeared while moving", m.to))
+		}
+		if err := rename(temps[i], dst); err != nil {
+			return rollback(err)
+		}
+		if err := record(step{from: temps[i], to: dst}); err != nil {
+			return rollback(err)
+		}
+	}
+
+	if err := journal.Close(); err != nil {
+		return err
+	}
+	return os.Remove(journalPath)
+}
+
+// mkdirAll is like os.MkdirAll but returns the directories it created,
+// outermost first.
+func mkdirAll(dir string) ([]string, error) {
+	if _, err := os.Stat(dir); err == nil {
+		return nil, nil
+	}
+	created, err := mkdirAll(filepath.Dir(dir))
+	if err != nil {
+		return created, err
+	}
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return created, err
+	}
+	return append(created, dir), nil
+}
+
+// parentDirs returns the directories above the slash-separated path p,
+// outermost first.
+func parentDirs(p string) []string {
+	var dirs []string
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	slices.Reverse(dirs)
+	return dirs
+}
+
+func osPath(root, path string) string {
+	return filepath.Join(root, filepath.FromSlash(path))
+}
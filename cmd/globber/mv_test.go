@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, data := range files {
+		path := osPath(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// readTree returns the contents of every file below root, and every empty
+// directory as a path ending in "/".
+func readTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	tree := make(map[string]string)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err == nil && len(entries) == 0 {
+				tree[rel+"/"] = ""
+			}
+			return err
+		}
+		data, err := os.ReadFile(path)
+		tree[rel] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestMv(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		from    string
+		to      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "into new directories",
+			files: map[string]string{"src/a.ts": "a", "src/b/c.ts": "c", "src/d.go": "d"},
+			from:  "src/**/*.ts",
+			to:    "dist/**/*.js",
+			want:  map[string]string{"dist/a.js": "a", "dist/b/c.js": "c", "src/b/": "", "src/d.go": "d"},
+		},
+		{
+			name:  "swap",
+			files: map[string]string{"a.txt": "a", "b.txt": "b"},
+			from:  "{a,b}.txt",
+			to:    "{b,a}.txt",
+			want:  map[string]string{"a.txt": "b", "b.txt": "a"},
+		},
+		{
+			name:  "chain",
+			files: map[string]string{"1.txt": "1", "2.txt": "2"},
+			from:  "{1,2}.txt",
+			to:    "{2,3}.txt",
+			want:  map[string]string{"2.txt": "1", "3.txt": "2"},
+		},
+		{
+			name:  "file replaced by directory",
+			files: map[string]string{"x": "x", "y.txt": "y"},
+			from:  "{x,y.txt}",
+			to:    "{z,x/y.txt}",
+			want:  map[string]string{"z": "x", "x/y.txt": "y"},
+		},
+		{
+			name:    "same target",
+			files:   map[string]string{"a.ts": "ts", "a.tsx": "tsx"},
+			from:    "*.{ts,tsx}",
+			to:      "*.js",
+			wantErr: true,
+		},
+		{
+			name:    "target exists",
+			files:   map[string]string{"a.ts": "ts", "a.js": "js"},
+			from:    "*.ts",
+			to:      "*.js",
+			wantErr: true,
+		},
+		{
+			name:    "target exists as directory",
+			files:   map[string]string{"a.ts": "ts", "a.js/x": "x"},
+			from:    "*.ts",
+			to:      "*.js",
+			wantErr: true,
+		},
+		{
+			name:    "target below another target",
+			files:   map[string]string{"a.txt": "a", "b.txt": "b"},
+			from:    "{a,b}.txt",
+			to:      "{x,x/y}",
+			wantErr: true,
+		},
+		{
+			name:    "target below file",
+			files:   map[string]string{"a.txt": "a", "x": "x"},
+			from:    "*.txt",
+			to:      "x/*.txt",
+			wantErr: true,
+		},
+		{
+			name:    "target below file deeper down",
+			files:   map[string]string{"a.txt": "a", "x": "x"},
+			from:    "*.txt",
+			to:      "x/y/*.txt",
+			wantErr: true,
+		},
+		{
+			name:    "invalid target",
+			files:   map[string]string{"a.txt": "a"},
+			from:    "*.txt",
+			to:      "../*.txt",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeTree(t, tt.files)
+			renamed := false
+			rename = func(from, to string) error {
+				renamed = true
+				return os.Rename(from, to)
+			}
+			defer func() { rename = os.Rename }()
+
+			err := runMv(root, tt.from, tt.to, false, io.Discard)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runMv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			want := tt.want
+			if tt.wantErr {
+				// Conflicts must be found before anything is moved.
+				if renamed {
+					t.Errorf("runMv() renamed files before failing with %v", err)
+				}
+				want = tt.files
+			}
+			if got := readTree(t, root); !maps.Equal(got, want) {
+				t.Errorf("tree = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMvDryRun(t *testing.T) {
+	files := map[string]string{"src/a.ts": "a", "src/b/c.ts": "c"}
+	root := writeTree(t, files)
+
+	var out strings.Builder
+	if err := runMv(root, "src/**/*.ts", "dist/**/*.js", true, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := "src/a.ts -> dist/a.js\nsrc/b/c.ts -> dist/b/c.js\nPlanned 2 moves, nothing changed\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+	if got := readTree(t, root); !maps.Equal(got, files) {
+		t.Errorf("tree = %v, want %v", got, files)
+	}
+}
+
+func TestMvRollback(t *testing.T) {
+	files := map[string]string{"a.txt": "a", "b.txt": "b", "src/c.txt": "c"}
+	// Three moves take six renames; fail each one in turn.
+	for fail := 1; fail <= 6; fail++ {
+		root := writeTree(t, files)
+
+		calls := 0
+		rename = func(from, to string) error {
+			calls++
+			if calls == fail {
+				return errors.New("injected failure")
+			}
+			return os.Rename(from, to)
+		}
+		err := runMv(root, "{a,b,src/c}.txt", "{b,out/a,src/out/c}.txt", false, io.Discard)
+		rename = os.Rename
+
+		if err == nil {
+			t.Fatalf("rename %d: runMv() succeeded, want error", fail)
+		}
+		if got := readTree(t, root); !maps.Equal(got, files) {
+			t.Errorf("rename %d: tree after rollback = %v, want %v", fail, got, files)
+		}
+	}
+}
+
+func TestMvJournal(t *testing.T) {
+	root := writeTree(t, map[string]string{"a.txt": "a"})
+	journalPath := filepath.Join(root, journalName)
+
+	var journal []byte
+	var temps []string
+	rename = func(from, to string) error {
+		if journal == nil {
+			var err error
+			if journal, err = os.ReadFile(journalPath); err != nil {
+				return err
+			}
+			temps = append(temps, filepath.Base(to))
+		}
+		return os.Rename(from, to)
+	}
+	defer func() { rename = os.Rename }()
+
+	var out strings.Builder
+	if err := runMv(root, "*.txt", "*.md", false, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "a.txt -> a.md\nMoved 1 file\n" {
+		t.Errorf("output = %q", out.String())
+	}
+	// The plan is on disk before the first rename.
+	plan := fmt.Sprintf("plan %q", filepath.Join(root, "a.txt"))
+	if !strings.HasPrefix(string(journal), plan) {
+		t.Errorf("journal at first rename = %q, want it to start with %q", journal, plan)
+	}
+	if len(temps) != 1 || !strings.HasPrefix(temps[0], ".globber-mv-") || !strings.HasSuffix(temps[0], "-a.txt") {
+		t.Errorf("temporary names = %q, want one ending in the source name", temps)
+	}
+	if _, err := os.Stat(journalPath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("journal left behind after success: %v", err)
+	}
+
+	t.Run("existing journal", func(t *testing.T) {
+		files := map[string]string{"b.txt": "b", journalName: "plan"}
+		root := writeTree(t, files)
+		if err := runMv(root, "*.txt", "*.md", false, io.Discard); err == nil {
+			t.Fatal("runMv() succeeded with a journal from an earlier run")
+		}
+		if got := readTree(t, root); !maps.Equal(got, files) {
+			t.Errorf("tree = %v, want %v", got, files)
+		}
+	})
+}
+
+func TestMvRollbackFailure(t *testing.T) {
+	root := writeTree(t, map[string]string{"a.txt": "a", "b.txt": "b"})
+
+	// Fail the second rename and then the rollback of the first.
+	calls := 0
+	rename = func(from, to string) error {
+		calls++
+		if calls >= 2 {
+			return errors.New("injected failure")
+		}
+		return os.Rename(from, to)
+	}
+	defer func() { rename = os.Rename }()
+
+	var out strings.Builder
+	err := runMv(root, "*.txt", "*.md", false, &out)
+	if err == nil {
+		t.Fatal("runMv() succeeded, want error")
+	}
+	if !strings.Contains(out.String(), "Rollback incomplete, remaining steps:\n  mv ") {
+		t.Errorf("output = %q, want the remaining rollback steps", out.String())
+	}
+	journal, readErr := os.ReadFile(filepath.Join(root, journalName))
+	if readErr != nil {
+		t.Fatalf("journal not kept after failed rollback: %v", readErr)
+	}
+	if !strings.Contains(string(journal), "rename ") {
+		t.Errorf("journal = %q, want the completed rename", journal)
+	}
+}
@@ -0,0 +1,171 @@
+package glob
+
+import (
+	"slices"
+	"unicode/utf8"
+)
+
+// captureIndex appends the wildcards of the nodes from n up to stop to nodes
+// in pattern order, including those in every alternative of a brace group,
+// and records their positions in index.
+func captureIndex(n, stop *node, nodes []*node, index map[*node]int) []*node {
+	for current := n; current != nil && current != stop; current = current.Next {
+		switch current.Type {
+		case tokenStar, tokenDoubleStar, tokenQuestion, tokenClass:
+			index[current] = len(nodes)
+			nodes = append(nodes, current)
+		case tokenBraceOpen:
+			for _, child := range current.Children {
+				nodes = captureIndex(child, current.Next, nodes, index)
+			}
+		}
+	}
+	return nodes
+}
+
+// braceGroups returns the brace groups at the top level of ast.
+func braceGroups(ast *node) []*node {
+	var groups []*node
+	for current := ast; current != nil; current = current.Next {
+		if current.Type == tokenBraceOpen {
+			groups = append(groups, current)
+		}
+	}
+	return groups
+}
+
+// MatchCaptures reports whether path matches the pattern and returns the
+// text bound to each wildcard ("*", "**", "?" and character classes) in
+// pattern order. Wildcards inside a brace alternative that did not match
+// capture the empty string. A "**" captures whole segments without the slash
+// that follows them, so "src/**/*.js" binds "a/b" and "c" for "src/a/b/c.js"
+// and "" and "c" for "src/c.js". Wildcards prefer the longest text that still
+// lets the rest of the pattern match. Negated patterns have no captures.
+func (m *Pattern) MatchCaptures(path string) ([]string, bool) {
+	if m.negate {
+		return nil, m.Match(path)
+	}
+
+	c, ok := m.capture(path)
+	if !ok {
+		return nil, false
+	}
+	captures := make([]string, len(m.captures))
+	for i := range captures {
+		captures[i] = path[c.spans[2*i]:c.spans[2*i+1]]
+	}
+	return captures, true
+}
+
+// capture matches path and returns the capturer holding the spans of the
+// wildcards and the alternatives taken by the top-level brace groups.
+func (m *Pattern) capture(path string) (*capturer, bool) {
+	c := &capturer{
+		path:   path,
+		index:  m.captureIndex,
+		groups: m.groups,
+		spans:  make([]int, 2*len(m.captures)),
+		alts:   make([]int, len(m.groups)),
+	}
+	return c, c.match(m.ast, 0)
+}
+
+// capturer is a backtracking matcher that records the span of every wildcard
+// on the route to a match. A brace group restores the spans it found on entry
+// before trying each alternative and when it fails, so only the alternative
+// that matched leaves spans behind. Spans outside brace groups are simply
+// overwritten, because those wildcards lie on every route.
+type capturer struct {
+	path   string
+	index  map[*node]int
+	groups []*node
+	spans  []int
+	alts   []int
+}
+
+func (c *capturer) set(n *node, start, end int) {
+	if i, ok := c.index[n]; ok {
+		c.spans[2*i] = start
+		c.spans[2*i+1] = end
+	}
+}
+
+func (c *capturer) match(n *node, i int) bool {
+	path := c.path
+	for ; n != nil; n = n.Next {
+		switch n.Type {
+		case tokenLiteral:
+			if !matchLiteral(path, i, n.Value) {
+				return false
+			}
+			i += len(n.Value)
+
+		case tokenDot:
+			if i >= len(path) || path[i] != '.' {
+				return false
+			}
+			i++
+
+		case tokenSlash:
+			if i >= len(path) || path[i] != '/' {
+				return false
+			}
+			i++
+
+		case tokenQuestion, tokenClass:
+			if i >= len(path) || path[i] == '/' {
+				return false
+			}
+			r, size := utf8.DecodeRuneInString(path[i:])
+			if n.Type == tokenClass && !n.Class.matches(r) {
+				return false
+			}
+			c.set(n, i, i+size)
+			i += size
+
+		case tokenStar:
+			_, end := findSlash(path, i)
+			for j := end; j >= i; j-- {
+				c.set(n, i, j)
+				if c.match(n.Next, j) {
+					return true
+				}
+			}
+			return false
+
+		case tokenDoubleStar:
+			for j := len(path); j >= i; j-- {
+				c.set(n, i, j)
+				if c.match(n.Next, j) {
+					return true
+				}
+			}
+			// "**/" at the start of a segment may also match zero segments.
+			if slash := slashAfter(n); slash != nil && (i == 0 || path[i-1] == '/') {
+				c.set(n, i, i)
+				return c.match(slash.Next, i)
+			}
+			return false
+
+		case tokenBraceOpen:
+			saved := slices.Clone(c.spans)
+			group := slices.Index(c.groups, n)
+			for k, child := range n.Children {
+				if group >= 0 {
+					c.alts[group] = k
+				}
+				if c.match(child, i) {
+					return true
+				}
+				copy(c.spans, saved)
+			}
+			return false
+
+		case tokenBraceClose:
+
+		default:
+			return false
+		}
+	}
+	return i == len(path)
+}
@@ -0,0 +1,85 @@
+package glob
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+type runeRange struct {
+	lo rune
+	hi rune
+}
+
+type charClass struct {
+	negate bool
+	ranges []runeRange
+}
+
+// parseClass parses a bracket expression such as "[a-z]" or "[!0-9]".
+// The literal must include the surrounding brackets. When escape is set, a
+// backslash makes the character after it literal, so "[\]]" matches "]" and
+// "[a\-z]" matches "a", "-" and "z".
+func parseClass(literal string, escape bool) (*charClass, error) {
+	body := literal[1 : len(literal)-1]
+	class := &charClass{}
+
+	if len(body) > 0 && (body[0] == '!' || body[0] == '^') {
+		class.negate = true
+		body = body[1:]
+	}
+
+	next := func() rune {
+		if escape && len(body) > 1 && body[0] == '\\' {
+			body = body[1:]
+		}
+		r, size := utf8.DecodeRuneInString(body)
+		body = body[size:]
+		return r
+	}
+
+	for len(body) > 0 {
+		lo := next()
+		hi := lo
+		if len(body) > 1 && body[0] == '-' {
+			body = body[1:]
+			hi = next()
+		}
+		if hi < lo {
+			return nil, fmt.Errorf("invalid range %q-%q", lo, hi)
+		}
+		class.ranges = append(class.ranges, runeRange{lo: lo, hi: hi})
+	}
+
+	return class, nil
+}
+
+func (c *charClass) matches(r rune) bool {
+	for _, rr := range c.ranges {
+		if r >= rr.lo && r <= rr.hi {
+			return !c.negate
+		}
+	}
+	return c.negate
+}
+
+// scanClass returns the end offset (exclusive) of the bracket expression that
+// starts at pattern[start], or -1 when the bracket is never closed. When escape
+// is set, a backslash keeps the character after it from closing the class.
+func scanClass(pattern string, start int, escape bool) int {
+	i := start + 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		i++
+	}
+	if i < len(pattern) && pattern[i] == ']' {
+		i++
+	}
+	for ; i < len(pattern); i++ {
+		switch {
+		case escape && pattern[i] == '\\':
+			i++
+		case pattern[i] == ']':
+			return i + 1
+		}
+	}
+	return -1
+}
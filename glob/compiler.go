@@ -1,9 +1,63 @@
 package glob
 
-func compile(pattern string) (*node, error) {
-	tokens, err := lex(pattern)
+import (
+	"errors"
+	"strings"
+)
+
+// Option configures how a pattern is compiled.
+type Option func(*options)
+
+type options struct {
+	noEscape bool
+}
+
+// NoEscape disables backslash escaping. Backslashes are read as path
+// separators instead, which suits Windows-style patterns such as `src\*.go`.
+func NoEscape() Option {
+	return func(o *options) {
+		o.noEscape = true
+	}
+}
+
+func compile(pattern string, opts ...Option) (*node, bool, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	tokens, err := lex(pattern, o)
 	if err != nil {
-		return nil, err
+		return nil, false, withPattern(err, pattern)
+	}
+	ast, negate, err := parse(tokens, o)
+	if err != nil {
+		return nil, false, withPattern(err, pattern)
+	}
+	if ast == nil {
+		ast = &node{Type: tokenLiteral}
+	}
+	return ast, negate, nil
+}
+
+func withPattern(err error, pattern string) error {
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) {
+		syntaxErr.Pattern = pattern
+	}
+	return err
+}
+
+// Escape returns a pattern that matches s literally by prefixing every
+// character with a special meaning with a backslash.
+func Escape(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', '{', '}', ',', '!', '\\':
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[i])
 	}
-	return parse(tokens)
+	return sb.String()
 }
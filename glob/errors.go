@@ -0,0 +1,31 @@
+package glob
+
+import (
+	"fmt"
+	"path"
+)
+
+// SyntaxError reports a malformed pattern. It matches path.ErrBadPattern
+// under errors.Is.
+type SyntaxError struct {
+	Pattern string // the pattern being compiled
+	Offset  int    // byte offset of the offending token
+	Token   string // text of the offending token
+	Msg     string // description of the problem
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("glob: %s at offset %d (%q) in pattern %q", e.Msg, e.Offset, e.Token, e.Pattern)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return path.ErrBadPattern
+}
+
+func syntaxError(tok token, format string, a ...interface{}) *SyntaxError {
+	return &SyntaxError{
+		Offset: tok.Pos,
+		Token:  tok.Literal,
+		Msg:    fmt.Sprintf(format, a...),
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package glob
+
+import "io/fs"
+
+func fileID(info fs.FileInfo) (dirID, bool) {
+	return dirID{}, false
+}
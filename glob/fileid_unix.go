@@ -0,0 +1,30 @@
+//go:build unix
+
+package glob
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func fileID(info fs.FileInfo) (dirID, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return dirID{}, false
+	}
+	return dirID{dev: devNumber(stat.Dev), ino: uint64(stat.Ino)}, true
+}
+
+// devNumber widens a device number, which is signed on darwin and openbsd,
+// 32 bits on dragonfly and some linux ports, and 64 bits elsewhere, without
+// sign extension.
+func devNumber[T int32 | uint32 | uint64](dev T) uint64 {
+	switch dev := any(dev).(type) {
+	case int32:
+		return uint64(uint32(dev))
+	case uint32:
+		return uint64(dev)
+	default:
+		return uint64(dev.(uint64))
+	}
+}
@@ -0,0 +1,127 @@
+package glob
+
+import (
+	"errors"
+	"io/fs"
+	"time"
+)
+
+// EntryType is a set of entry types, combined with |.
+type EntryType uint8
+
+const (
+	// TypeFile is a regular file.
+	TypeFile EntryType = 1 << iota
+	// TypeDir is a directory.
+	TypeDir
+	// TypeSymlink is a symbolic link, whether or not the walk follows it.
+	TypeSymlink
+	// TypeOther is anything else: named pipes, sockets, devices and so on.
+	TypeOther
+
+	// TypeAll matches every entry.
+	TypeAll = TypeFile | TypeDir | TypeSymlink | TypeOther
+)
+
+func entryType(entry fs.DirEntry) EntryType {
+	mode := entry.Type()
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		return TypeSymlink
+	case mode.IsDir():
+		return TypeDir
+	case mode.IsRegular():
+		return TypeFile
+	default:
+		return TypeOther
+	}
+}
+
+// filter restricts which matching entries a walk reports. It never affects
+// which directories are read. The zero value reports everything.
+type filter struct {
+	types EntryType
+
+	sized            bool
+	minSize, maxSize int64
+
+	after, before time.Time
+
+	executable *bool
+}
+
+// needsInfo reports whether accept has to call entry.Info.
+func (f *filter) needsInfo() bool {
+	return f.sized || !f.after.IsZero() || !f.before.IsZero() || f.executable != nil
+}
+
+// accept reports whether a matching entry passes the filter. Entries that
+// disappear before their metadata can be read are rejected.
+func (f *filter) accept(entry fs.DirEntry) (bool, error) {
+	if f.types != 0 && f.types&entryType(entry) == 0 {
+		return false, nil
+	}
+	if !f.needsInfo() {
+		return true, nil
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if f.sized {
+		size := info.Size()
+		if size < f.minSize || (f.maxSize >= 0 && size > f.maxSize) {
+			return false, nil
+		}
+	}
+	modTime := info.ModTime()
+	if !f.after.IsZero() && modTime.Before(f.after) {
+		return false, nil
+	}
+	if !f.before.IsZero() && !modTime.Before(f.before) {
+		return false, nil
+	}
+	if f.executable != nil && (info.Mode()&0o111 != 0) != *f.executable {
+		return false, nil
+	}
+	return true, nil
+}
+
+// WithTypes limits walks to reporting entries of the given types and returns
+// fm. Zero reports every type. Directories are still read when they are not
+// reported.
+func (fm *FSPattern) WithTypes(types EntryType) *FSPattern {
+	fm.filter.types = types
+	return fm
+}
+
+// WithSizeRange limits walks to reporting entries whose size in bytes is
+// between lo and hi inclusive and returns fm. A negative hi means there is no
+// upper bound.
+func (fm *FSPattern) WithSizeRange(lo, hi int64) *FSPattern {
+	fm.filter.sized = true
+	fm.filter.minSize = lo
+	fm.filter.maxSize = hi
+	return fm
+}
+
+// WithModTimeRange limits walks to reporting entries modified at or after
+// after and before before, and returns fm. A zero time leaves that end of the
+// window open.
+func (fm *FSPattern) WithModTimeRange(after, before time.Time) *FSPattern {
+	fm.filter.after = after
+	fm.filter.before = before
+	return fm
+}
+
+// WithExecutable limits walks to reporting entries that have, or do not have,
+// any execute permission bit set, and returns fm.
+func (fm *FSPattern) WithExecutable(executable bool) *FSPattern {
+	fm.filter.executable = &executable
+	return fm
+}
@@ -1,87 +1,259 @@
 package glob
 
 import (
+	"context"
+	"errors"
 	"io/fs"
-	"path/filepath"
+	"os"
+	"sync"
 )
 
-type fSMatcher struct {
-	matcher *matcher
+// WalkFunc is called for every entry that matches during a walk. As with
+// fs.WalkDir, returning fs.SkipDir from a directory skips its contents,
+// returning fs.SkipDir from a file skips the remaining entries of its
+// directory, and returning fs.SkipAll stops the walk without error. Any other
+// non-nil error stops the walk and is returned to the caller.
+type WalkFunc func(path string, entry fs.DirEntry) error
+
+// FSPattern is a Pattern that can walk file systems and report matching
+// entries. Paths passed to callbacks are slash-separated and relative to the
+// walk root.
+type FSPattern struct {
+	pattern  *Pattern
+	matcher  Matcher
+	excluder Excluder
+
+	workers    int
+	concurrent bool
+	sorted     bool
+	policy     ErrorPolicy
+	onError    func(path string, err error) error
+
+	followSymlinks bool
+	minDepth       int
+	maxDepth       int
+
+	filter filter
 }
 
-func FSMatcher(pattern string) *fSMatcher {
-	return &fSMatcher{
-		matcher: Matcher(pattern),
+// ErrorPolicy decides what a walk does when a directory cannot be read.
+type ErrorPolicy int
+
+const (
+	// AbortOnError stops the walk and returns the error. This is the default.
+	AbortOnError ErrorPolicy = iota
+	// SkipOnError skips the unreadable directory and continues.
+	SkipOnError
+	// CollectErrors skips the unreadable directory, continues, and returns
+	// all such errors joined together once the walk has finished, along with
+	// the error that ended the walk early, if any.
+	CollectErrors
+)
+
+// Excluder removes entries from a walk before they are matched. Excluded
+// entries are not reported and excluded directories are not read.
+type Excluder interface {
+	// Enter is called for every directory that is about to be read, starting
+	// with the root ".", and returns the Excluder that applies to its entries.
+	Enter(fsys fs.FS, dir string) (Excluder, error)
+	// Excluded reports whether the entry at path should be skipped.
+	Excluded(path string, isDir bool) bool
+}
+
+// CompileFS compiles pattern for use with file system walks.
+func CompileFS(pattern string, opts ...Option) (*FSPattern, error) {
+	p, err := Compile(pattern, opts...)
+	if err != nil {
+		return nil, err
 	}
+	return &FSPattern{pattern: p, matcher: p}, nil
 }
 
-func (fm *fSMatcher) Matches(path string) (bool, error) {
-	return fm.matcher.Matches(path)
+// FSMatcher is like CompileFS but panics if the pattern cannot be parsed.
+func FSMatcher(pattern string, opts ...Option) *FSPattern {
+	p := MustCompile(pattern, opts...)
+	return &FSPattern{pattern: p, matcher: p}
 }
 
-func (fm *fSMatcher) Walk(fsys fs.FS, fn func(path string, entry fs.DirEntry) error) error {
-	return fm.walkSerial(fsys, ".", fn)
+// NewFSMatcher returns an FSPattern that walks file systems with m, such as a
+// RuleSet or PatternSet. If m has a CouldMatchPrefix(dir string) bool method,
+// walks use it to skip directories that cannot contain matches, and if it has
+// a MaxDepth() int method, to stop descending below that depth.
+func NewFSMatcher(m Matcher) *FSPattern {
+	p, _ := m.(*Pattern)
+	return &FSPattern{pattern: p, matcher: m}
 }
 
-func (fm *fSMatcher) walkSerial(fsys fs.FS, dir string, fn func(path string, entry fs.DirEntry) error) error {
-	entries, err := fs.ReadDir(fsys, dir)
-	if err != nil {
-		return err
-	}
+// Pattern returns the underlying compiled pattern, or nil if fm was created
+// by NewFSMatcher from another kind of Matcher.
+func (fm *FSPattern) Pattern() *Pattern {
+	return fm.pattern
+}
 
-	for _, entry := range entries {
-		var path string
-		if dir == "." {
-			path = entry.Name()
-		} else {
-			path = dir + "/" + entry.Name()
-		}
+// Match reports whether path matches the pattern.
+func (fm *FSPattern) Match(path string) bool {
+	return fm.matcher.Match(path)
+}
 
-		matches, err := fm.Matches(path)
-		if err != nil {
-			return err
-		}
+// Matches reports whether path matches the pattern.
+func (fm *FSPattern) Matches(path string) (bool, error) {
+	if fm.pattern != nil {
+		return fm.pattern.Matches(path)
+	}
+	return fm.matcher.Match(path), nil
+}
 
-		if matches {
-			if err := fn(path, entry); err != nil {
-				return err
-			}
-		}
+// couldMatchPrefix reports whether the matcher could match below dir.
+func (fm *FSPattern) couldMatchPrefix(dir string) bool {
+	if m, ok := fm.matcher.(interface{ CouldMatchPrefix(string) bool }); ok {
+		return m.CouldMatchPrefix(dir)
+	}
+	return true
+}
 
-		if entry.IsDir() {
-			if err := fm.walkSerial(fsys, path, fn); err != nil {
-				return err
-			}
-		}
+// matcherDepth returns the matcher's own depth limit, or zero if it has none.
+func (fm *FSPattern) matcherDepth() int {
+	if m, ok := fm.matcher.(interface{ MaxDepth() int }); ok {
+		return max(m.MaxDepth(), 0)
 	}
+	return 0
+}
 
-	return nil
+// WithExcluder sets the Excluder consulted while walking and returns fm.
+func (fm *FSPattern) WithExcluder(excluder Excluder) *FSPattern {
+	fm.excluder = excluder
+	return fm
 }
 
-func (fm *fSMatcher) WalkDirFS(rootPath string, fn func(path string, entry fs.DirEntry) error) error {
-	return filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+// WithWorkers sets how many directories a walk reads concurrently and returns
+// fm. Values below 2 walk serially on the calling goroutine.
+func (fm *FSPattern) WithWorkers(n int) *FSPattern {
+	fm.workers = n
+	return fm
+}
 
-		relPath, err := filepath.Rel(rootPath, path)
-		if err != nil {
-			return err
-		}
+// WithConcurrentCallbacks controls whether a parallel walk may call fn from
+// several goroutines at once and returns fm. When disabled, the default, fn
+// is only ever called from the goroutine that started the walk.
+func (fm *FSPattern) WithConcurrentCallbacks(enabled bool) *FSPattern {
+	fm.concurrent = enabled
+	return fm
+}
+
+// WithSortedOutput controls whether matches are buffered and delivered in the
+// order of a serial walk once the tree has been read, and returns fm. Sorted
+// output is reproducible regardless of the number of workers.
+func (fm *FSPattern) WithSortedOutput(enabled bool) *FSPattern {
+	fm.sorted = enabled
+	return fm
+}
+
+// WithErrorPolicy sets how unreadable directories are handled and returns fm.
+func (fm *FSPattern) WithErrorPolicy(policy ErrorPolicy) *FSPattern {
+	fm.policy = policy
+	return fm
+}
+
+// WithOnError sets a hook that is called with the path of every directory
+// that cannot be read and returns fm. Returning nil skips the directory and
+// continues the walk; returning an error stops the walk with that error. The
+// hook takes precedence over the error policy and is only called from one
+// goroutine at a time.
+func (fm *FSPattern) WithOnError(fn func(path string, err error) error) *FSPattern {
+	fm.onError = fn
+	return fm
+}
+
+// WithFollowSymlinks controls whether walks descend into symbolic links that
+// point at directories and returns fm. Matches below a link are reported
+// under the link's path. A link that leads back into a directory that is
+// already being walked is not followed; it is reported to the error hook or
+// policy as ErrSymlinkCycle, so under the default AbortOnError the first
+// cycle stops the walk. Use SkipOnError or WithOnError to skip cycles and
+// carry on.
+//
+// Where the platform has no device and inode numbers, as on Windows, links
+// are resolved through ReadLink and Lstat methods of the file system, which
+// os.DirFS only has from Go 1.25. On file systems without them every followed
+// link is reported as an error.
+func (fm *FSPattern) WithFollowSymlinks(enabled bool) *FSPattern {
+	fm.followSymlinks = enabled
+	return fm
+}
+
+// WithMaxDepth limits walks to entries at most n levels below the root and
+// returns fm. Top-level entries are at depth 1, and directories at depth n are
+// reported but not read. Zero removes the limit. Patterns without "**" have a
+// fixed depth of their own, which walks apply automatically.
+func (fm *FSPattern) WithMaxDepth(n int) *FSPattern {
+	fm.maxDepth = n
+	return fm
+}
+
+// WithMinDepth stops walks from reporting entries less than n levels below
+// the root and returns fm. Shallower directories are still read.
+func (fm *FSPattern) WithMinDepth(n int) *FSPattern {
+	fm.minDepth = n
+	return fm
+}
 
-		if relPath == "." {
+// Walk walks fsys from its root and calls fn for every matching entry.
+//
+// All walks share the same contract:
+//   - The root itself is never reported; paths are relative to it.
+//   - Paths always use '/' as the separator, whatever the operating system.
+//   - Serial walks are depth-first, visit the entries of each directory in
+//     lexical order and report a directory before its contents. Parallel
+//     walks report matches in no particular order unless sorted output is
+//     enabled.
+//   - An error reading a directory stops the walk and is returned, unless an
+//     error policy or hook says otherwise. Errors from fn always stop it.
+//   - Excluded directories and directories the pattern cannot match below are
+//     not read.
+func (fm *FSPattern) Walk(fsys fs.FS, fn WalkFunc) error {
+	return fm.WalkContext(context.Background(), fsys, fn)
+}
+
+// WalkContext is like Walk but stops as soon as ctx is done, in which case it
+// returns ctx.Err(). Cancellation is checked before every directory read.
+func (fm *FSPattern) WalkContext(ctx context.Context, fsys fs.FS, fn WalkFunc) error {
+	w := &walker{fm: fm, ctx: ctx, fsys: fsys, fn: fn, maxDepth: fm.maxDepth}
+	if depth := fm.matcherDepth(); depth > 0 && (w.maxDepth <= 0 || depth < w.maxDepth) {
+		w.maxDepth = depth
+	}
+
+	var mu sync.Mutex
+	var matches []walkMatch
+	if fm.sorted {
+		w.fn = func(path string, entry fs.DirEntry) error {
+			mu.Lock()
+			matches = append(matches, walkMatch{path: path, entry: entry})
+			mu.Unlock()
 			return nil
 		}
+	}
 
-		matches, err := fm.Matches(filepath.ToSlash(relPath))
-		if err != nil {
-			return err
-		}
+	err := w.run()
+	if err == nil && fm.sorted {
+		err = deliverSorted(ctx, matches, fn)
+	}
+	if err == fs.SkipAll {
+		err = nil
+	}
+	if len(w.errs) > 0 {
+		err = errors.Join(append([]error{err}, w.errs...)...)
+	}
+	return err
+}
 
-		if matches {
-			return fn(filepath.ToSlash(relPath), d)
-		}
+// WalkDirFS walks the operating system directory rootPath. It is equivalent
+// to calling Walk with os.DirFS(rootPath) and follows the same contract.
+func (fm *FSPattern) WalkDirFS(rootPath string, fn WalkFunc) error {
+	return fm.WalkDirFSContext(context.Background(), rootPath, fn)
+}
 
-		return nil
-	})
+// WalkDirFSContext is like WalkDirFS but stops as soon as ctx is done, in
+// which case it returns ctx.Err().
+func (fm *FSPattern) WalkDirFSContext(ctx context.Context, rootPath string, fn WalkFunc) error {
+	return fm.WalkContext(ctx, os.DirFS(rootPath), fn)
 }
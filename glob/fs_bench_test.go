@@ -3,6 +3,7 @@ package glob
 import (
 	"fmt"
 	"io/fs"
+	"runtime"
 	"testing"
 	"testing/fstest"
 )
@@ -59,5 +60,70 @@ func BenchmarkWalk(b *testing.B) {
 				})
 			}
 		})
+
+		b.Run(size.name+"Parallel", func(b *testing.B) {
+			m := FSMatcher("**/*.go").WithWorkers(runtime.NumCPU())
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				count := 0
+				_ = m.Walk(testFS, func(path string, entry fs.DirEntry) error {
+					count++
+					return nil
+				})
+			}
+		})
+	}
+}
+
+func createDeepFS(depth, width, filesPerDir int) fstest.MapFS {
+	testFS := make(fstest.MapFS)
+
+	var fill func(dir string, level int)
+	fill = func(dir string, level int) {
+		for f := 0; f < filesPerDir; f++ {
+			testFS[fmt.Sprintf("%s/file%d.go", dir, f)] = &fstest.MapFile{Data: []byte("package main")}
+		}
+		if level == depth {
+			return
+		}
+		for w := 0; w < width; w++ {
+			fill(fmt.Sprintf("%s/d%d", dir, w), level+1)
+		}
 	}
+	fill("src", 0)
+	testFS["src/pkg/main.go"] = &fstest.MapFile{Data: []byte("package main")}
+
+	return testFS
+}
+
+func BenchmarkWalkPruning(b *testing.B) {
+	testFS := createDeepFS(5, 4, 5)
+	pattern := "src/pkg/*.go"
+
+	b.Run("Pruned", func(b *testing.B) {
+		m := FSMatcher(pattern)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = m.Walk(testFS, func(path string, entry fs.DirEntry) error {
+				return nil
+			})
+		}
+	})
+
+	b.Run("Unpruned", func(b *testing.B) {
+		m := MustCompile(pattern)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = fs.WalkDir(testFS, ".", func(path string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				m.Match(path)
+				return nil
+			})
+		}
+	})
 }
@@ -0,0 +1,48 @@
+package glob
+
+import (
+	"errors"
+	"io/fs"
+	"iter"
+)
+
+// Entry is a matching file system entry together with its path.
+type Entry struct {
+	Path string
+	fs.DirEntry
+}
+
+var errStopIteration = errors.New("glob: iteration stopped")
+
+// All returns an iterator over the matching entries of fsys, in the same
+// order Walk would report them. Walk errors end the iteration silently; use
+// Entries to observe them.
+func (fm *FSPattern) All(fsys fs.FS) iter.Seq2[string, fs.DirEntry] {
+	return func(yield func(string, fs.DirEntry) bool) {
+		for entry, err := range fm.Entries(fsys) {
+			if err != nil || !yield(entry.Path, entry.DirEntry) {
+				return
+			}
+		}
+	}
+}
+
+// Entries returns an iterator over the matching entries of fsys. If the walk
+// fails, the final pair carries the error and a zero Entry.
+func (fm *FSPattern) Entries(fsys fs.FS) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		// yield must not be called concurrently.
+		serial := *fm
+		serial.concurrent = false
+
+		err := serial.Walk(fsys, func(path string, entry fs.DirEntry) error {
+			if !yield(Entry{Path: path, DirEntry: entry}, nil) {
+				return errStopIteration
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStopIteration) {
+			yield(Entry{}, err)
+		}
+	}
+}
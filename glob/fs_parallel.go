@@ -0,0 +1,166 @@
+package glob
+
+import (
+	"io/fs"
+	"sync"
+)
+
+type walkMatch struct {
+	path  string
+	entry fs.DirEntry
+}
+
+// walkItem is a directory entry that is either reported, descended into or
+// both, kept in directory order so skips apply to the right siblings.
+type walkItem struct {
+	path    string
+	entry   fs.DirEntry
+	match   bool
+	descend bool
+}
+
+type walkBatch struct {
+	dir       string
+	depth     int
+	items     []walkItem
+	excluder  Excluder
+	ancestors *ancestor
+	jobs      []walkJob
+	readErr   error
+	err       error
+}
+
+// parallel reads directories on fm.workers worker goroutines. The calling
+// goroutine coordinates the walk: it owns the queue of directories still to
+// read, hands them to idle workers, handles directory errors and, unless
+// concurrent callbacks are enabled, delivers every match to fn itself.
+func (w *walker) parallel(root walkJob) error {
+	jobs := make(chan walkJob)
+	results := make(chan walkBatch)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for i := 0; i < w.fm.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				batch := w.readBatch(job)
+				select {
+				case results <- batch:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	var err error
+	queue := []walkJob{root}
+	active := 0
+	for (len(queue) > 0 || active > 0) && err == nil {
+		// A nil channel blocks, so jobs are only offered while some wait.
+		var send chan walkJob
+		var next walkJob
+		if len(queue) > 0 {
+			send = jobs
+			next = queue[len(queue)-1]
+		}
+
+		var batch walkBatch
+		select {
+		case send <- next:
+			queue = queue[:len(queue)-1]
+			active++
+			continue
+		case batch = <-results:
+			active--
+		case <-w.ctx.Done():
+			err = w.ctx.Err()
+			continue
+		}
+
+		switch {
+		case batch.readErr != nil:
+			err = w.dirError(batch.dir, batch.readErr)
+		case batch.err != nil:
+			err = batch.err
+		default:
+			if !w.fm.concurrent {
+				batch.jobs, err = deliver(&batch, w.fn)
+			}
+			queue = append(queue, batch.jobs...)
+		}
+	}
+
+	close(done)
+	close(jobs)
+	wg.Wait()
+	return err
+}
+
+// readBatch reads a single directory for parallel. With concurrent
+// callbacks the matches are delivered to fn here and only the directories to
+// descend into are returned.
+func (w *walker) readBatch(job walkJob) walkBatch {
+	batch := walkBatch{dir: job.dir, depth: job.depth}
+	if batch.err = w.ctx.Err(); batch.err != nil {
+		return batch
+	}
+
+	var entries []fs.DirEntry
+	entries, batch.excluder, batch.ancestors, batch.readErr = w.open(job)
+	if batch.readErr != nil {
+		return batch
+	}
+
+	for _, entry := range entries {
+		path := joinPath(job.dir, entry.Name())
+
+		matches, descend, err := w.visit(path, batch.depth+1, entry, batch.excluder)
+		if err != nil {
+			batch.err = err
+			return batch
+		}
+		if matches || descend {
+			batch.items = append(batch.items, walkItem{path: path, entry: entry, match: matches, descend: descend})
+		}
+	}
+
+	if w.fm.concurrent {
+		batch.jobs, batch.err = deliver(&batch, w.fn)
+	}
+	return batch
+}
+
+// deliver calls fn for the matching items of one directory and returns the
+// subdirectories to walk next. It follows fs.WalkDir: fs.SkipDir from a
+// directory skips its contents, fs.SkipDir from a file skips the remaining
+// entries of the directory, and any other error, including fs.SkipAll, is
+// returned.
+func deliver(batch *walkBatch, fn WalkFunc) ([]walkJob, error) {
+	var jobs []walkJob
+	for _, item := range batch.items {
+		if item.match {
+			if err := fn(item.path, item.entry); err != nil {
+				if err != fs.SkipDir {
+					return nil, err
+				}
+				if !item.entry.IsDir() {
+					break
+				}
+				continue
+			}
+		}
+		if item.descend {
+			jobs = append(jobs, walkJob{
+				dir:       item.path,
+				depth:     batch.depth + 1,
+				entry:     item.entry,
+				excluder:  batch.excluder,
+				ancestors: batch.ancestors,
+			})
+		}
+	}
+	return jobs, nil
+}
@@ -1,9 +1,20 @@
 package glob
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
 	"testing"
 	"testing/fstest"
+	"time"
 )
 
 func TestMatcherWithFS(t *testing.T) {
@@ -42,13 +53,11 @@ func TestMatcherWithFS(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.pattern, func(t *testing.T) {
-			m := FSMatcher(tt.pattern)
+			m := FSMatcher(tt.pattern).WithTypes(TypeFile)
 
 			var got []string
 			err := m.Walk(testFS, func(path string, entry fs.DirEntry) error {
-				if !entry.IsDir() {
-					got = append(got, path)
-				}
+				got = append(got, path)
 				return nil
 			})
 
@@ -74,3 +83,979 @@ func TestMatcherWithFS(t *testing.T) {
 		})
 	}
 }
+
+func TestWalkZeroDirectories(t *testing.T) {
+	mapFS := fstest.MapFS{
+		"main.go":        {Data: []byte("root")},
+		"src/a.go":       {Data: []byte("a")},
+		"src/b/c.go":     {Data: []byte("c")},
+		"src/b/y/d.go":   {Data: []byte("d")},
+		"src/b/x/y/e.go": {Data: []byte("e")},
+		"src/x.txt":      {Data: []byte("x")},
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{pattern: "**/*.go", want: []string{"main.go", "src/a.go", "src/b/c.go", "src/b/x/y/e.go", "src/b/y/d.go"}},
+		{pattern: "src/**/*.go", want: []string{"src/a.go", "src/b/c.go", "src/b/x/y/e.go", "src/b/y/d.go"}},
+		{pattern: "src/b/**/y/*.go", want: []string{"src/b/x/y/e.go", "src/b/y/d.go"}},
+		{pattern: "src/{a,b/**}/*.go", want: []string{"src/b/c.go", "src/b/x/y/e.go", "src/b/y/d.go"}},
+		{pattern: "**/main.go", want: []string{"main.go"}},
+		{pattern: "src/b**/*.go", want: []string{"src/b/c.go", "src/b/x/y/e.go", "src/b/y/d.go"}},
+	}
+
+	for _, tt := range tests {
+		for _, workers := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s/workers=%d", tt.pattern, workers), func(t *testing.T) {
+				var got []string
+				err := FSMatcher(tt.pattern).
+					WithTypes(TypeFile).
+					WithWorkers(workers).
+					WithSortedOutput(true).
+					Walk(mapFS, func(path string, entry fs.DirEntry) error {
+						got = append(got, path)
+						return nil
+					})
+				if err != nil {
+					t.Fatalf("Walk() error = %v", err)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("Walk() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+type readDirFS struct {
+	fstest.MapFS
+	read []string
+}
+
+func (f *readDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f.read = append(f.read, name)
+	return f.MapFS.ReadDir(name)
+}
+
+func TestWalkPrunesDirectories(t *testing.T) {
+	fsys := &readDirFS{MapFS: fstest.MapFS{
+		"src/pkg/a.go":            {Data: []byte("package pkg")},
+		"src/pkg/internal/b.go":   {Data: []byte("package internal")},
+		"src/cmd/main.go":         {Data: []byte("package main")},
+		"vendor/x/y/z/c.go":       {Data: []byte("package z")},
+		"docs/guide/index.md":     {Data: []byte("# guide")},
+		"src/pkg/testdata/d.json": {Data: []byte("{}")},
+	}}
+
+	var got []string
+	err := FSMatcher("src/pkg/*.go").Walk(fsys, func(path string, entry fs.DirEntry) error {
+		got = append(got, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+
+	if len(got) != 1 || got[0] != "src/pkg/a.go" {
+		t.Errorf("Walk() = %v, want [src/pkg/a.go]", got)
+	}
+
+	wantRead := []string{".", "src", "src/pkg"}
+	if len(fsys.read) != len(wantRead) {
+		t.Fatalf("read directories %v, want %v", fsys.read, wantRead)
+	}
+	for i, dir := range wantRead {
+		if fsys.read[i] != dir {
+			t.Errorf("read directories %v, want %v", fsys.read, wantRead)
+			break
+		}
+	}
+}
+
+func TestParallelWalk(t *testing.T) {
+	testFS := createLargeFS(20, 10)
+
+	var want []string
+	err := FSMatcher("**/*.go").Walk(testFS, func(path string, entry fs.DirEntry) error {
+		want = append(want, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+	sort.Strings(want)
+
+	tests := []struct {
+		name string
+		fm   *FSPattern
+	}{
+		{name: "serialized", fm: FSMatcher("**/*.go").WithWorkers(4)},
+		{name: "concurrent", fm: FSMatcher("**/*.go").WithWorkers(4).WithConcurrentCallbacks(true)},
+		{name: "sorted", fm: FSMatcher("**/*.go").WithWorkers(4).WithSortedOutput(true)},
+		{name: "sorted concurrent", fm: FSMatcher("**/*.go").WithWorkers(8).WithConcurrentCallbacks(true).WithSortedOutput(true)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var got []string
+			err := tt.fm.Walk(testFS, func(path string, entry fs.DirEntry) error {
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, path)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Walk() error = %v", err)
+			}
+
+			if !tt.fm.sorted {
+				sort.Strings(got)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("Walk() returned %d paths, want %d", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestParallelWalkStopsOnError(t *testing.T) {
+	testFS := createLargeFS(20, 10)
+	errStop := errors.New("stop")
+
+	calls := 0
+	err := FSMatcher("**/*.go").WithWorkers(4).Walk(testFS, func(path string, entry fs.DirEntry) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("Walk() error = %v, want %v", err, errStop)
+	}
+	if calls != 1 {
+		t.Errorf("fn called %d times after error, want 1", calls)
+	}
+}
+
+// goroutineFS records the largest number of goroutines seen while reading
+// directories.
+type goroutineFS struct {
+	fstest.MapFS
+	mu   sync.Mutex
+	peak int
+}
+
+func (f *goroutineFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f.mu.Lock()
+	f.peak = max(f.peak, runtime.NumGoroutine())
+	f.mu.Unlock()
+	return f.MapFS.ReadDir(name)
+}
+
+func TestParallelWalkBoundsGoroutines(t *testing.T) {
+	mapFS := fstest.MapFS{}
+	for i := 0; i < 1000; i++ {
+		mapFS[fmt.Sprintf("d%d/f.go", i)] = &fstest.MapFile{}
+	}
+	fsys := &goroutineFS{MapFS: mapFS}
+
+	const workers = 2
+	before := runtime.NumGoroutine()
+	calls := 0
+	err := FSMatcher("**/*.go").WithWorkers(workers).Walk(fsys, func(path string, entry fs.DirEntry) error {
+		calls++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+	if calls != 1000 {
+		t.Errorf("fn called %d times, want 1000", calls)
+	}
+	if limit := before + workers; fsys.peak > limit {
+		t.Errorf("peak of %d goroutines during walk, want at most %d", fsys.peak, limit)
+	}
+}
+
+func TestWalkContextCancel(t *testing.T) {
+	testFS := createLargeFS(20, 10)
+
+	tests := []struct {
+		name string
+		fm   *FSPattern
+	}{
+		{name: "serial", fm: FSMatcher("**/*.go")},
+		{name: "parallel", fm: FSMatcher("**/*.go").WithWorkers(4)},
+		{name: "parallel concurrent", fm: FSMatcher("**/*.go").WithWorkers(4).WithConcurrentCallbacks(true)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var mu sync.Mutex
+			calls := 0
+			err := tt.fm.WalkContext(ctx, testFS, func(path string, entry fs.DirEntry) error {
+				mu.Lock()
+				defer mu.Unlock()
+				calls++
+				cancel()
+				return nil
+			})
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("WalkContext() error = %v, want %v", err, context.Canceled)
+			}
+			if calls >= 20*5 {
+				t.Errorf("fn called %d times, walk did not stop", calls)
+			}
+		})
+	}
+
+	t.Run("already canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := FSMatcher("**").WalkDirFSContext(ctx, t.TempDir(), func(path string, entry fs.DirEntry) error {
+			t.Errorf("fn called for %s", path)
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("WalkDirFSContext() error = %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestAll(t *testing.T) {
+	testFS := createLargeFS(5, 4)
+	m := FSMatcher("**/*.go")
+
+	var want []string
+	_ = m.Walk(testFS, func(path string, entry fs.DirEntry) error {
+		want = append(want, path)
+		return nil
+	})
+
+	var got []string
+	for path, entry := range m.All(testFS) {
+		if entry == nil {
+			t.Fatalf("nil entry for %s", path)
+		}
+		got = append(got, path)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("All() = %v, want %v", got, want)
+	}
+
+	got = got[:0]
+	for path := range m.WithWorkers(4).WithConcurrentCallbacks(true).All(testFS) {
+		got = append(got, path)
+		if len(got) == 3 {
+			break
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("All() yielded %d paths after break, want 3", len(got))
+	}
+}
+
+func TestEntriesError(t *testing.T) {
+	var got []string
+	var gotErr error
+	for entry, err := range FSMatcher("**").Entries(fstest.MapFS{}) {
+		if err != nil {
+			gotErr = err
+			continue
+		}
+		got = append(got, entry.Path)
+	}
+	if gotErr != nil || len(got) != 0 {
+		t.Errorf("Entries() on empty FS = %v, %v", got, gotErr)
+	}
+
+	for entry, err := range FSMatcher("**").Entries(errFS{}) {
+		if err == nil {
+			t.Errorf("Entries() yielded %s, want error", entry.Path)
+		}
+		gotErr = err
+	}
+	if !errors.Is(gotErr, fs.ErrPermission) {
+		t.Errorf("Entries() error = %v, want %v", gotErr, fs.ErrPermission)
+	}
+}
+
+type errFS struct{}
+
+func (errFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+}
+
+func writeTree(t *testing.T, mapFS fstest.MapFS) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, file := range mapFS {
+		osPath := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(osPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(osPath, file.Data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestWalkSkip(t *testing.T) {
+	mapFS := fstest.MapFS{
+		"a/1.txt":     {Data: []byte("1")},
+		"a/2.txt":     {Data: []byte("2")},
+		"a/3.txt":     {Data: []byte("3")},
+		"a/z/4.txt":   {Data: []byte("4")},
+		"b/1.txt":     {Data: []byte("1")},
+		"b/sub/2.txt": {Data: []byte("2")},
+		"c/1.txt":     {Data: []byte("1")},
+		"c/2.txt":     {Data: []byte("2")},
+		"d.txt":       {Data: []byte("d")},
+		"d/a/x":       {Data: []byte("x")},
+		"d/a.txt":     {Data: []byte("a")},
+	}
+	root := writeTree(t, mapFS)
+
+	tests := []struct {
+		name     string
+		skip     map[string]error
+		parallel bool
+		want     []string
+	}{
+		{
+			name:     "skip dir",
+			skip:     map[string]error{"b": fs.SkipDir},
+			parallel: true,
+			want:     []string{"a", "a/1.txt", "a/2.txt", "a/3.txt", "a/z", "a/z/4.txt", "b", "c", "c/1.txt", "c/2.txt", "d", "d.txt", "d/a", "d/a.txt", "d/a/x"},
+		},
+		{
+			name:     "skip dir from file skips siblings",
+			skip:     map[string]error{"a/2.txt": fs.SkipDir},
+			parallel: true,
+			want:     []string{"a", "a/1.txt", "a/2.txt", "b", "b/1.txt", "b/sub", "b/sub/2.txt", "c", "c/1.txt", "c/2.txt", "d", "d.txt", "d/a", "d/a.txt", "d/a/x"},
+		},
+		{
+			// The serial walk reports d/a/x before d/a.txt, so a skip from
+			// d/a.txt must not drop it in sorted mode either.
+			name:     "skip dir from file after subdirectory",
+			skip:     map[string]error{"d/a.txt": fs.SkipDir},
+			parallel: true,
+			want:     []string{"a", "a/1.txt", "a/2.txt", "a/3.txt", "a/z", "a/z/4.txt", "b", "b/1.txt", "b/sub", "b/sub/2.txt", "c", "c/1.txt", "c/2.txt", "d", "d.txt", "d/a", "d/a.txt", "d/a/x"},
+		},
+		{
+			name: "skip all",
+			skip: map[string]error{"c/1.txt": fs.SkipAll},
+			want: []string{"a", "a/1.txt", "a/2.txt", "a/3.txt", "a/z", "a/z/4.txt", "b", "b/1.txt", "b/sub", "b/sub/2.txt", "c", "c/1.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		walkers := map[string]func(fn WalkFunc) error{
+			"Walk": func(fn WalkFunc) error {
+				return FSMatcher("**").Walk(mapFS, fn)
+			},
+			"WalkDirFS": func(fn WalkFunc) error {
+				return FSMatcher("**").WalkDirFS(root, fn)
+			},
+			"WalkSorted": func(fn WalkFunc) error {
+				return FSMatcher("**").WithSortedOutput(true).Walk(mapFS, fn)
+			},
+		}
+		if tt.parallel {
+			walkers["WalkParallel"] = func(fn WalkFunc) error {
+				return FSMatcher("**").WithWorkers(4).Walk(mapFS, fn)
+			}
+		}
+
+		for name, walk := range walkers {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				var got []string
+				err := walk(func(path string, entry fs.DirEntry) error {
+					got = append(got, path)
+					return tt.skip[path]
+				})
+				if err != nil {
+					t.Fatalf("walk error = %v", err)
+				}
+				sort.Strings(got)
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestWalkConformance(t *testing.T) {
+	mapFS := fstest.MapFS{
+		"a/b.go":          {Data: []byte("package a")},
+		"a/c/d.go":        {Data: []byte("package c")},
+		"a-b.txt":         {Data: []byte("text")},
+		"src/main.go":     {Data: []byte("package main")},
+		"src/util/x.go":   {Data: []byte("package util")},
+		"src/README.md":   {Data: []byte("# src")},
+		"z.go":            {Data: []byte("package z")},
+		".hidden/file.go": {Data: []byte("package hidden")},
+	}
+	root := writeTree(t, mapFS)
+
+	walkers := []struct {
+		name string
+		walk func(fm *FSPattern, fn WalkFunc) error
+	}{
+		{name: "Walk MapFS", walk: func(fm *FSPattern, fn WalkFunc) error { return fm.Walk(mapFS, fn) }},
+		{name: "Walk DirFS", walk: func(fm *FSPattern, fn WalkFunc) error { return fm.Walk(os.DirFS(root), fn) }},
+		{name: "WalkDirFS", walk: func(fm *FSPattern, fn WalkFunc) error { return fm.WalkDirFS(root, fn) }},
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{
+			pattern: "**",
+			want: []string{
+				".hidden", ".hidden/file.go",
+				"a", "a/b.go", "a/c", "a/c/d.go",
+				"a-b.txt",
+				"src", "src/README.md", "src/main.go", "src/util", "src/util/x.go",
+				"z.go",
+			},
+		},
+		{
+			pattern: "**/*.go",
+			want:    []string{".hidden/file.go", "a/b.go", "a/c/d.go", "src/main.go", "src/util/x.go", "z.go"},
+		},
+		{
+			pattern: "src/*",
+			want:    []string{"src/README.md", "src/main.go", "src/util"},
+		},
+		{
+			pattern: "*",
+			want:    []string{".hidden", "a", "a-b.txt", "src", "z.go"},
+		},
+	}
+
+	for _, walker := range walkers {
+		for _, tt := range tests {
+			t.Run(walker.name+"/"+tt.pattern, func(t *testing.T) {
+				var got []string
+				err := walker.walk(FSMatcher(tt.pattern), func(path string, entry fs.DirEntry) error {
+					got = append(got, path)
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("walk error = %v", err)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+
+	missing := filepath.Join(root, "missing")
+	if err := FSMatcher("**").Walk(os.DirFS(missing), func(string, fs.DirEntry) error { return nil }); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Walk() missing root error = %v, want %v", err, fs.ErrNotExist)
+	}
+	if err := FSMatcher("**").WalkDirFS(missing, func(string, fs.DirEntry) error { return nil }); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("WalkDirFS() missing root error = %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+type lockedFS struct {
+	fstest.MapFS
+	locked map[string]bool
+}
+
+func (f lockedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if f.locked[name] {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrPermission}
+	}
+	return f.MapFS.ReadDir(name)
+}
+
+func TestWalkErrorPolicy(t *testing.T) {
+	fsys := lockedFS{
+		MapFS: fstest.MapFS{
+			"a/1.txt":       {Data: []byte("1")},
+			"locked/2.txt":  {Data: []byte("2")},
+			"private/3.txt": {Data: []byte("3")},
+			"z/4.txt":       {Data: []byte("4")},
+		},
+		locked: map[string]bool{"locked": true, "private": true},
+	}
+
+	var hooked []string
+	tests := []struct {
+		name       string
+		fm         *FSPattern
+		want       []string
+		wantErrors int
+	}{
+		{name: "abort", fm: FSMatcher("**/*.txt"), want: []string{"a/1.txt"}, wantErrors: 1},
+		{name: "skip", fm: FSMatcher("**/*.txt").WithErrorPolicy(SkipOnError), want: []string{"a/1.txt", "z/4.txt"}},
+		{name: "collect", fm: FSMatcher("**/*.txt").WithErrorPolicy(CollectErrors), want: []string{"a/1.txt", "z/4.txt"}, wantErrors: 2},
+		{name: "collect parallel", fm: FSMatcher("**/*.txt").WithErrorPolicy(CollectErrors).WithWorkers(4).WithSortedOutput(true), want: []string{"a/1.txt", "z/4.txt"}, wantErrors: 2},
+		{name: "hook", fm: FSMatcher("**/*.txt").WithOnError(func(path string, err error) error {
+			hooked = append(hooked, path)
+			return nil
+		}), want: []string{"a/1.txt", "z/4.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := tt.fm.Walk(fsys, func(path string, entry fs.DirEntry) error {
+				got = append(got, path)
+				return nil
+			})
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Walk() = %v, want %v", got, tt.want)
+			}
+			if tt.wantErrors == 0 {
+				if err != nil {
+					t.Errorf("Walk() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, fs.ErrPermission) {
+				t.Fatalf("Walk() error = %v, want %v", err, fs.ErrPermission)
+			}
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				if n := len(joined.Unwrap()); n != tt.wantErrors {
+					t.Errorf("Walk() returned %d errors, want %d", n, tt.wantErrors)
+				}
+			} else if tt.wantErrors != 1 {
+				t.Errorf("Walk() returned a single error, want %d", tt.wantErrors)
+			}
+		})
+	}
+
+	if !slices.Equal(hooked, []string{"locked", "private"}) {
+		t.Errorf("OnError called for %v, want [locked private]", hooked)
+	}
+
+	t.Run("collect then stop", func(t *testing.T) {
+		errStop := errors.New("stop")
+		err := FSMatcher("**/*.txt").WithErrorPolicy(CollectErrors).Walk(fsys, func(path string, entry fs.DirEntry) error {
+			if path == "z/4.txt" {
+				return errStop
+			}
+			return nil
+		})
+		if !errors.Is(err, errStop) || !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("Walk() error = %v, want both %v and %v", err, errStop, fs.ErrPermission)
+		}
+	})
+}
+
+// pathOnlyFS hides device and inode numbers so that symlinks have to be
+// resolved by path.
+type pathOnlyFS struct {
+	root string
+	fsys fs.FS
+}
+
+type pathOnlyInfo struct{ fs.FileInfo }
+
+func (pathOnlyInfo) Sys() any { return nil }
+
+type pathOnlyEntry struct{ fs.DirEntry }
+
+func (e pathOnlyEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return pathOnlyInfo{info}, nil
+}
+
+func (f pathOnlyFS) Open(name string) (fs.File, error) { return f.fsys.Open(name) }
+
+func (f pathOnlyFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := fs.Stat(f.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return pathOnlyInfo{info}, nil
+}
+
+func (f pathOnlyFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(f.fsys, name)
+	for i, entry := range entries {
+		entries[i] = pathOnlyEntry{entry}
+	}
+	return entries, err
+}
+
+func (f pathOnlyFS) ReadLink(name string) (string, error) {
+	return os.Readlink(filepath.Join(f.root, filepath.FromSlash(name)))
+}
+
+func (f pathOnlyFS) Lstat(name string) (fs.FileInfo, error) {
+	info, err := os.Lstat(filepath.Join(f.root, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	return pathOnlyInfo{info}, nil
+}
+
+func TestWalkFollowSymlinks(t *testing.T) {
+	root := writeTree(t, fstest.MapFS{
+		"a/file.txt": {Data: []byte("a")},
+		"b/keep.txt": {Data: []byte("b")},
+	})
+	links := map[string]string{
+		"a/loop": "..",
+		"a/l1":   "l2",
+		"a/l2":   "..",
+		"b/link": "../a",
+		"self":   ".",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			t.Skipf("cannot create symlinks: %v", err)
+		}
+	}
+
+	want := []string{"a/file.txt", "b/keep.txt", "b/link/file.txt"}
+	wantCycles := []string{"a/l1", "a/l2", "a/loop", "b/link/l1", "b/link/l2", "b/link/loop", "self"}
+
+	filesystems := map[string]fs.FS{
+		"DirFS":    os.DirFS(root),
+		"PathOnly": pathOnlyFS{root: root, fsys: os.DirFS(root)},
+	}
+	for name, fsys := range filesystems {
+		for _, workers := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s/workers=%d", name, workers), func(t *testing.T) {
+				var mu sync.Mutex
+				var cycles []string
+				m := FSMatcher("**/*.txt").
+					WithFollowSymlinks(true).
+					WithWorkers(workers).
+					WithSortedOutput(true).
+					WithOnError(func(path string, err error) error {
+						if !errors.Is(err, ErrSymlinkCycle) {
+							return err
+						}
+						mu.Lock()
+						cycles = append(cycles, path)
+						mu.Unlock()
+						return nil
+					})
+
+				var got []string
+				err := m.Walk(fsys, func(path string, entry fs.DirEntry) error {
+					got = append(got, path)
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("Walk() error = %v", err)
+				}
+				if !slices.Equal(got, want) {
+					t.Errorf("Walk() = %v, want %v", got, want)
+				}
+				sort.Strings(cycles)
+				if !slices.Equal(cycles, wantCycles) {
+					t.Errorf("cycles = %v, want %v", cycles, wantCycles)
+				}
+			})
+		}
+	}
+
+	t.Run("cycle aborts by default", func(t *testing.T) {
+		err := FSMatcher("**/*.txt").WithFollowSymlinks(true).WalkDirFS(root, func(path string, entry fs.DirEntry) error {
+			return nil
+		})
+		if !errors.Is(err, ErrSymlinkCycle) {
+			t.Errorf("WalkDirFS() error = %v, want %v", err, ErrSymlinkCycle)
+		}
+	})
+
+	t.Run("not followed by default", func(t *testing.T) {
+		var got []string
+		err := FSMatcher("**/*.txt").WalkDirFS(root, func(path string, entry fs.DirEntry) error {
+			got = append(got, path)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkDirFS() error = %v", err)
+		}
+		if !slices.Equal(got, []string{"a/file.txt", "b/keep.txt"}) {
+			t.Errorf("WalkDirFS() = %v", got)
+		}
+	})
+}
+
+func TestWalkDepth(t *testing.T) {
+	mapFS := fstest.MapFS{
+		"1.txt":         {Data: []byte("1")},
+		"a/2.txt":       {Data: []byte("2")},
+		"a/b/3.txt":     {Data: []byte("3")},
+		"a/b/c/4.txt":   {Data: []byte("4")},
+		"a/b/c/d/5.txt": {Data: []byte("5")},
+	}
+
+	tests := []struct {
+		name     string
+		fm       *FSPattern
+		want     []string
+		wantRead []string
+	}{
+		{
+			name:     "max depth",
+			fm:       FSMatcher("**/*.txt").WithMaxDepth(2),
+			want:     []string{"1.txt", "a/2.txt"},
+			wantRead: []string{".", "a"},
+		},
+		{
+			name:     "min depth",
+			fm:       FSMatcher("**/*.txt").WithMinDepth(3),
+			want:     []string{"a/b/3.txt", "a/b/c/4.txt", "a/b/c/d/5.txt"},
+			wantRead: []string{".", "a", "a/b", "a/b/c", "a/b/c/d"},
+		},
+		{
+			name:     "min and max depth",
+			fm:       FSMatcher("**").WithMinDepth(2).WithMaxDepth(3),
+			want:     []string{"a/2.txt", "a/b", "a/b/3.txt", "a/b/c"},
+			wantRead: []string{".", "a", "a/b"},
+		},
+		{
+			name:     "pattern depth",
+			fm:       FSMatcher("*/*"),
+			want:     []string{"a/2.txt", "a/b"},
+			wantRead: []string{".", "a"},
+		},
+		{
+			name:     "pattern depth below max depth",
+			fm:       FSMatcher("{*,*/*}").WithMaxDepth(10),
+			want:     []string{"1.txt", "a", "a/2.txt", "a/b"},
+			wantRead: []string{".", "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := &readDirFS{MapFS: mapFS}
+			var got []string
+			err := tt.fm.Walk(fsys, func(path string, entry fs.DirEntry) error {
+				got = append(got, path)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Walk() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Walk() = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(fsys.read, tt.wantRead) {
+				t.Errorf("read directories %v, want %v", fsys.read, tt.wantRead)
+			}
+		})
+	}
+}
+
+// infoCountFS counts calls to Info on the entries it returns.
+type infoCountFS struct {
+	fstest.MapFS
+	calls int
+}
+
+type infoCountEntry struct {
+	fs.DirEntry
+	fsys *infoCountFS
+}
+
+func (e infoCountEntry) Info() (fs.FileInfo, error) {
+	e.fsys.calls++
+	return e.DirEntry.Info()
+}
+
+func (f *infoCountFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := f.MapFS.ReadDir(name)
+	for i, entry := range entries {
+		entries[i] = infoCountEntry{DirEntry: entry, fsys: f}
+	}
+	return entries, err
+}
+
+func TestWalkFilters(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mapFS := fstest.MapFS{
+		"empty.txt":   {Data: nil, ModTime: now.Add(-48 * time.Hour)},
+		"small.txt":   {Data: []byte("1234"), ModTime: now.Add(-time.Hour)},
+		"large.txt":   {Data: make([]byte, 100), ModTime: now},
+		"run.sh":      {Data: []byte("#!/bin/sh"), Mode: 0o755, ModTime: now},
+		"link":        {Data: []byte("run.sh"), Mode: fs.ModeSymlink},
+		"pipe":        {Mode: fs.ModeNamedPipe},
+		"dir":         {Mode: fs.ModeDir | 0o755, ModTime: now},
+		"dir/sub.txt": {Data: []byte("sub"), ModTime: now},
+	}
+
+	tests := []struct {
+		name      string
+		fm        *FSPattern
+		want      []string
+		wantInfos bool
+	}{
+		{
+			name: "files",
+			fm:   FSMatcher("**").WithTypes(TypeFile),
+			want: []string{"dir/sub.txt", "empty.txt", "large.txt", "run.sh", "small.txt"},
+		},
+		{
+			name: "dirs",
+			fm:   FSMatcher("**").WithTypes(TypeDir),
+			want: []string{"dir"},
+		},
+		{
+			name: "symlinks and other",
+			fm:   FSMatcher("**").WithTypes(TypeSymlink | TypeOther),
+			want: []string{"link", "pipe"},
+		},
+		{
+			name:      "size range",
+			fm:        FSMatcher("**/*.txt").WithSizeRange(1, 10),
+			want:      []string{"dir/sub.txt", "small.txt"},
+			wantInfos: true,
+		},
+		{
+			name:      "minimum size",
+			fm:        FSMatcher("*.txt").WithSizeRange(5, -1),
+			want:      []string{"large.txt"},
+			wantInfos: true,
+		},
+		{
+			name:      "modified window",
+			fm:        FSMatcher("*.txt").WithModTimeRange(now.Add(-2*time.Hour), now),
+			want:      []string{"small.txt"},
+			wantInfos: true,
+		},
+		{
+			name:      "modified after",
+			fm:        FSMatcher("**/*.txt").WithModTimeRange(now, time.Time{}),
+			want:      []string{"dir/sub.txt", "large.txt"},
+			wantInfos: true,
+		},
+		{
+			name:      "executable",
+			fm:        FSMatcher("*").WithTypes(TypeFile).WithExecutable(true),
+			want:      []string{"run.sh"},
+			wantInfos: true,
+		},
+		{
+			name:      "not executable",
+			fm:        FSMatcher("*").WithTypes(TypeFile).WithExecutable(false),
+			want:      []string{"empty.txt", "large.txt", "small.txt"},
+			wantInfos: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := &infoCountFS{MapFS: mapFS}
+			var got []string
+			err := tt.fm.Walk(fsys, func(path string, entry fs.DirEntry) error {
+				got = append(got, path)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Walk() error = %v", err)
+			}
+			sort.Strings(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Walk() = %v, want %v", got, tt.want)
+			}
+			if gotInfos := fsys.calls > 0; gotInfos != tt.wantInfos {
+				t.Errorf("Info() called %d times, want calls = %v", fsys.calls, tt.wantInfos)
+			}
+		})
+	}
+}
+
+func TestGlob(t *testing.T) {
+	mapFS := fstest.MapFS{
+		"a.go":         {},
+		"b.txt":        {},
+		"src/main.go":  {},
+		"src/util.go":  {},
+		"src/x/y.go":   {},
+		"docs/read.md": {},
+	}
+
+	// Patterns that fs.Glob understands must give the same answer.
+	for _, pattern := range []string{"*", "*.go", "src/*.go", "*/*", "src", "[ab].*", "missing/*"} {
+		t.Run(pattern, func(t *testing.T) {
+			got, err := Glob(mapFS, pattern)
+			if err != nil {
+				t.Fatalf("Glob() error = %v", err)
+			}
+			want, _ := fs.Glob(mapFS, pattern)
+			if !slices.Equal(got, want) {
+				t.Errorf("Glob() = %v, fs.Glob() = %v", got, want)
+			}
+		})
+	}
+
+	got, err := Glob(mapFS, "**/*.{go,md}")
+	if err != nil {
+		t.Fatalf("Glob() error = %v", err)
+	}
+	want := []string{"a.go", "docs/read.md", "src/main.go", "src/util.go", "src/x/y.go"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Glob() = %v, want %v", got, want)
+	}
+
+	if _, err := Glob(mapFS, "[a-"); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("Glob() error = %v, want path.ErrBadPattern", err)
+	}
+}
+
+func TestGlobOS(t *testing.T) {
+	root := writeTree(t, fstest.MapFS{
+		"a/b.go": {Data: []byte("package a")},
+		"c.go":   {Data: []byte("package c")},
+	})
+
+	got, err := GlobOS(root, "**/*.go")
+	if err != nil {
+		t.Fatalf("GlobOS() error = %v", err)
+	}
+	if want := []string{"a/b.go", "c.go"}; !slices.Equal(got, want) {
+		t.Errorf("GlobOS() = %v, want %v", got, want)
+	}
+}
+
+func TestWalkRuleSet(t *testing.T) {
+	fsys := &readDirFS{MapFS: fstest.MapFS{
+		"src/app/main.ts":      {},
+		"src/app/main.test.ts": {},
+		"src/lib/util.ts":      {},
+		"docs/guide.md":        {},
+		"README.md":            {},
+	}}
+	rules := MustCompileRules("src/**/*.ts", "*.md", "!**/*.test.ts")
+
+	var got []string
+	err := NewFSMatcher(rules).Walk(fsys, func(path string, entry fs.DirEntry) error {
+		got = append(got, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+	if want := []string{"README.md", "src/app/main.ts", "src/lib/util.ts"}; !slices.Equal(got, want) {
+		t.Errorf("Walk() = %v, want %v", got, want)
+	}
+	if slices.Contains(fsys.read, "docs") {
+		t.Errorf("read directories %v, want docs pruned", fsys.read)
+	}
+}
@@ -0,0 +1,36 @@
+package glob
+
+import (
+	"io/fs"
+	"os"
+	"slices"
+)
+
+// Glob returns the paths in fsys that match pattern, sorted and without
+// duplicates. Like fs.Glob it ignores I/O errors and only fails if pattern
+// is malformed, in which case the error matches path.ErrBadPattern under
+// errors.Is. Unlike fs.Glob, pattern may use the full glob syntax, including
+// "**" and brace alternation.
+func Glob(fsys fs.FS, pattern string) ([]string, error) {
+	fm, err := CompileFS(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	err = fm.WithErrorPolicy(SkipOnError).Walk(fsys, func(path string, entry fs.DirEntry) error {
+		matches = append(matches, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(matches)
+	return slices.Compact(matches), nil
+}
+
+// GlobOS is like Glob for the operating system directory root. The returned
+// paths are relative to root.
+func GlobOS(root, pattern string) ([]string, error) {
+	return Glob(os.DirFS(root), pattern)
+}
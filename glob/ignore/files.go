@@ -0,0 +1,59 @@
+package ignore
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+
+	"github.com/azuyamat/globber/glob"
+)
+
+// Files returns a glob.Excluder that looks for ignore files with the given
+// names in every directory a walk enters, such as ".gitignore" and ".ignore".
+// Rules only apply below the directory holding their file, and rules from
+// deeper files take precedence over rules from their parents. When several
+// names are given, files later in the list take precedence.
+func Files(names ...string) glob.Excluder {
+	return &scope{names: names, dir: "."}
+}
+
+type scope struct {
+	names    []string
+	parent   *scope
+	dir      string
+	matchers []*Matcher
+}
+
+func (s *scope) Enter(fsys fs.FS, dir string) (glob.Excluder, error) {
+	var matchers []*Matcher
+	for _, name := range s.names {
+		m, err := ParseFile(fsys, path.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	if len(matchers) == 0 {
+		return s, nil
+	}
+
+	return &scope{names: s.names, parent: s, dir: dir, matchers: matchers}, nil
+}
+
+func (s *scope) Excluded(path string, isDir bool) bool {
+	for current := s; current != nil; current = current.parent {
+		rel := path
+		if current.dir != "." {
+			rel = path[len(current.dir)+1:]
+		}
+		for i := len(current.matchers) - 1; i >= 0; i-- {
+			if ignored, rule := current.matchers[i].Decide(rel, isDir); rule != nil {
+				return ignored
+			}
+		}
+	}
+	return false
+}
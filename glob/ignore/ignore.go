@@ -0,0 +1,190 @@
+// Package ignore implements .gitignore rule matching on top of the glob
+// compiler.
+package ignore
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/azuyamat/globber/glob"
+)
+
+// Rule is a single pattern line from an ignore file.
+type Rule struct {
+	Line     int    // 1-based line number in the source
+	Text     string // the pattern as written, without trailing spaces
+	Negate   bool   // the rule starts with '!' and re-includes paths
+	DirOnly  bool   // the rule ends with '/' and only matches directories
+	Anchored bool   // the rule contains a '/' and only matches relative to its file
+
+	pattern *glob.Pattern
+}
+
+// Match reports whether the rule's pattern matches path, ignoring negation.
+func (r *Rule) Match(path string, isDir bool) bool {
+	if r.DirOnly && !isDir {
+		return false
+	}
+	return r.pattern.Match(path)
+}
+
+// Matcher holds the ordered rules of an ignore file. Paths passed to it are
+// slash-separated and relative to the directory containing the file.
+type Matcher struct {
+	rules []Rule
+}
+
+// New builds a Matcher from the lines of an ignore file.
+func New(lines ...string) *Matcher {
+	m := &Matcher{}
+	for i, line := range lines {
+		if rule, ok := parseRule(line); ok {
+			rule.Line = i + 1
+			m.rules = append(m.rules, rule)
+		}
+	}
+	return m
+}
+
+// Parse reads an ignore file from r.
+func Parse(r io.Reader) (*Matcher, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return New(lines...), nil
+}
+
+// ParseFile reads the ignore file name from fsys.
+func ParseFile(fsys fs.FS, name string) (*Matcher, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Rules returns the parsed rules in file order.
+func (m *Matcher) Rules() []Rule {
+	return m.rules
+}
+
+// Ignored reports whether path is ignored. As in git, a path inside an ignored
+// directory stays ignored even if a later rule would re-include it.
+func (m *Matcher) Ignored(path string, isDir bool) bool {
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' {
+			if ignored, _ := m.Decide(path[:i], true); ignored {
+				return true
+			}
+		}
+	}
+	ignored, _ := m.Decide(path, isDir)
+	return ignored
+}
+
+// Decide returns the verdict of the last rule matching path, without looking
+// at parent directories. The returned rule is nil if no rule matched.
+func (m *Matcher) Decide(path string, isDir bool) (bool, *Rule) {
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		rule := &m.rules[i]
+		if rule.Match(path, isDir) {
+			return !rule.Negate, rule
+		}
+	}
+	return false, nil
+}
+
+func parseRule(line string) (Rule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	line = trimTrailingSpaces(line)
+	if line == "" || line[0] == '#' {
+		return Rule{}, false
+	}
+
+	rule := Rule{Text: line}
+	if line[0] == '!' {
+		rule.Negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.DirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.Anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return Rule{}, false
+	}
+
+	pattern := translate(line)
+	if strings.HasPrefix(pattern, "!") {
+		pattern = `\` + pattern
+	}
+	if !rule.Anchored {
+		pattern = "**/" + pattern
+	}
+	compiled, err := glob.Compile(pattern)
+	if err != nil {
+		// git silently skips patterns it cannot parse.
+		return Rule{}, false
+	}
+	rule.pattern = compiled
+	return rule, true
+}
+
+// trimTrailingSpaces removes trailing spaces unless they are escaped with a
+// backslash.
+func trimTrailingSpaces(line string) string {
+	end := len(line)
+	for end > 0 && line[end-1] == ' ' {
+		end--
+	}
+	if end < len(line) && end > 0 && line[end-1] == '\\' {
+		end++
+	}
+	return line[:end]
+}
+
+// translate rewrites gitignore syntax into glob syntax. Braces and commas have
+// no meaning in gitignore and are escaped, and "**" is only special when it
+// forms a whole path segment; elsewhere it behaves like "*".
+func translate(line string) string {
+	segments := strings.Split(line, "/")
+	for i, segment := range segments {
+		if segment == "**" {
+			continue
+		}
+		var sb strings.Builder
+		for j := 0; j < len(segment); j++ {
+			ch := segment[j]
+			switch {
+			case ch == '\\' && j+1 < len(segment):
+				sb.WriteByte(ch)
+				sb.WriteByte(segment[j+1])
+				j++
+			case ch == '*':
+				sb.WriteByte('*')
+				for j+1 < len(segment) && segment[j+1] == '*' {
+					j++
+				}
+			case ch == '{' || ch == '}' || ch == ',':
+				sb.WriteByte('\\')
+				sb.WriteByte(ch)
+			default:
+				sb.WriteByte(ch)
+			}
+		}
+		segments[i] = sb.String()
+	}
+	return strings.Join(segments, "/")
+}
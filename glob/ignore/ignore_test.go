@@ -0,0 +1,186 @@
+package ignore
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/azuyamat/globber/glob"
+)
+
+// Cases follow the examples in git's gitignore documentation.
+func TestIgnored(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{name: "blank lines and comments", rules: []string{"", "# comment", "   "}, path: "# comment", want: false},
+		{name: "escaped hash", rules: []string{`\#file`}, path: "#file", want: true},
+		{name: "escaped bang", rules: []string{`\!important!.txt`}, path: "!important!.txt", want: true},
+		{name: "trailing spaces ignored", rules: []string{"foo.txt   "}, path: "foo.txt", want: true},
+		{name: "escaped trailing space kept", rules: []string{`foo\ `}, path: "foo ", want: true},
+		{name: "escaped trailing space kept no match", rules: []string{`foo\ `}, path: "foo", want: false},
+
+		{name: "no slash matches at root", rules: []string{"hello.*"}, path: "hello.txt", want: true},
+		{name: "no slash matches at any depth", rules: []string{"hello.*"}, path: "a/hello.c", want: true},
+		{name: "leading slash anchors", rules: []string{"/hello.*"}, path: "hello.txt", want: true},
+		{name: "leading slash anchors no match", rules: []string{"/hello.*"}, path: "a/hello.java", want: false},
+		{name: "middle slash anchors", rules: []string{"doc/frotz"}, path: "doc/frotz", want: true},
+		{name: "middle slash anchors no match", rules: []string{"doc/frotz"}, path: "a/doc/frotz", want: false},
+
+		{name: "trailing slash matches directory", rules: []string{"frotz/"}, path: "a/frotz", isDir: true, want: true},
+		{name: "trailing slash skips files", rules: []string{"frotz/"}, path: "a/frotz", want: false},
+		{name: "trailing slash anchored", rules: []string{"doc/frotz/"}, path: "doc/frotz", isDir: true, want: true},
+		{name: "trailing slash anchored no match", rules: []string{"doc/frotz/"}, path: "a/doc/frotz", isDir: true, want: false},
+		{name: "contents of ignored directory", rules: []string{"frotz/"}, path: "frotz/file.txt", want: true},
+
+		{name: "star does not cross slash", rules: []string{"foo/*"}, path: "foo/test.json", want: true},
+		{name: "star matches directory", rules: []string{"foo/*"}, path: "foo/bar", isDir: true, want: true},
+		{name: "star file below matched directory", rules: []string{"foo/*"}, path: "foo/bar/hello.c", want: true},
+		{name: "question mark", rules: []string{"file?.log"}, path: "logs/file1.log", want: true},
+		{name: "range", rules: []string{"log-[0-9].txt"}, path: "log-7.txt", want: true},
+		{name: "braces are literal", rules: []string{"{a,b}.txt"}, path: "{a,b}.txt", want: true},
+		{name: "braces are literal no match", rules: []string{"{a,b}.txt"}, path: "a.txt", want: false},
+
+		{name: "leading double star", rules: []string{"**/foo"}, path: "foo", want: true},
+		{name: "leading double star nested", rules: []string{"**/foo"}, path: "x/y/foo", want: true},
+		{name: "leading double star with path", rules: []string{"**/foo/bar"}, path: "x/foo/bar", want: true},
+		{name: "trailing double star contents", rules: []string{"abc/**"}, path: "abc/x/y", want: true},
+		{name: "trailing double star not directory itself", rules: []string{"abc/**"}, path: "abc", isDir: true, want: false},
+		{name: "middle double star zero", rules: []string{"a/**/b"}, path: "a/b", want: true},
+		{name: "middle double star one", rules: []string{"a/**/b"}, path: "a/x/b", want: true},
+		{name: "middle double star many", rules: []string{"a/**/b"}, path: "a/x/y/b", want: true},
+		{name: "double star inside segment is star", rules: []string{"a**b"}, path: "a/x/b", want: false},
+
+		{name: "negation re-includes", rules: []string{"*.html", "!foo.html"}, path: "foo.html", want: false},
+		{name: "negation other files stay", rules: []string{"*.html", "!foo.html"}, path: "bar.html", want: true},
+		{name: "last rule wins", rules: []string{"!foo.html", "*.html"}, path: "foo.html", want: true},
+		{name: "cannot re-include below ignored dir", rules: []string{"build/", "!build/keep.txt"}, path: "build/keep.txt", want: true},
+		{name: "exclude all except foo/bar", rules: []string{"/*", "!/foo", "/foo/*", "!/foo/bar"}, path: "foo/bar/a.txt", want: false},
+		{name: "exclude all except foo/bar sibling", rules: []string{"/*", "!/foo", "/foo/*", "!/foo/bar"}, path: "foo/baz", isDir: true, want: true},
+		{name: "exclude all except foo/bar root file", rules: []string{"/*", "!/foo", "/foo/*", "!/foo/bar"}, path: "top.txt", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(tt.rules...)
+			if got := m.Ignored(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Ignored(%q, %v) = %v, want %v, rules: %q", tt.path, tt.isDir, got, tt.want, tt.rules)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	m, err := Parse(strings.NewReader("# build output\nbin/\n\n*.log\n!keep.log\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	rules := m.Rules()
+	if len(rules) != 3 {
+		t.Fatalf("got %d rules, want 3", len(rules))
+	}
+	if rules[0].Line != 2 || !rules[0].DirOnly {
+		t.Errorf("rules[0] = %+v, want directory rule on line 2", rules[0])
+	}
+	if rules[2].Line != 5 || !rules[2].Negate {
+		t.Errorf("rules[2] = %+v, want negated rule on line 5", rules[2])
+	}
+
+	ignored, rule := m.Decide("keep.log", false)
+	if ignored || rule == nil || rule.Line != 5 {
+		t.Errorf("Decide() = %v, %+v, want re-included by line 5", ignored, rule)
+	}
+}
+
+type readDirFS struct {
+	fstest.MapFS
+	read []string
+}
+
+func (f *readDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f.read = append(f.read, name)
+	return f.MapFS.ReadDir(name)
+}
+
+func TestFilesWalk(t *testing.T) {
+	mapFS := fstest.MapFS{
+		".gitignore":                {Data: []byte("node_modules/\n*.log\n")},
+		".ignore":                   {Data: []byte("bazel-out/\n")},
+		"src/main.go":               {Data: []byte("package main")},
+		"src/debug.log":             {Data: []byte("log")},
+		"node_modules/pkg/index.js": {Data: []byte("js")},
+		"bazel-out/bin/app":         {Data: []byte("bin")},
+		"pkg/.gitignore":            {Data: []byte("generated.go\n!keep.log\n")},
+		"pkg/generated.go":          {Data: []byte("package pkg")},
+		"pkg/lib.go":                {Data: []byte("package pkg")},
+		"pkg/keep.log":              {Data: []byte("log")},
+		"other/generated.go":        {Data: []byte("package other")},
+	}
+	fsys := &readDirFS{MapFS: mapFS}
+
+	m := glob.FSMatcher("**/*").WithExcluder(Files(".gitignore", ".ignore"))
+
+	var got []string
+	err := m.Walk(fsys, func(path string, entry fs.DirEntry) error {
+		if !entry.IsDir() {
+			got = append(got, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+
+	want := []string{
+		".gitignore",
+		".ignore",
+		"other/generated.go",
+		"pkg/.gitignore",
+		"pkg/keep.log",
+		"pkg/lib.go",
+		"src/main.go",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Walk() = %v, want %v", got, want)
+	}
+
+	for _, dir := range fsys.read {
+		if strings.HasPrefix(dir, "node_modules") || strings.HasPrefix(dir, "bazel-out") {
+			t.Errorf("Walk() read ignored directory %s", dir)
+		}
+	}
+
+	root := t.TempDir()
+	for name, file := range mapFS {
+		osPath := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(osPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(osPath, file.Data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var gotOS []string
+	err = m.WalkDirFS(root, func(path string, entry fs.DirEntry) error {
+		if !entry.IsDir() {
+			gotOS = append(gotOS, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDirFS() error = %v", err)
+	}
+	if !slices.Equal(gotOS, want) {
+		t.Errorf("WalkDirFS() = %v, want %v", gotOS, want)
+	}
+}
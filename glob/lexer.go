@@ -1,32 +1,81 @@
 package glob
 
-func lex(pattern string) ([]token, error) {
+import "unicode/utf8"
+
+func lex(pattern string, opts options) ([]token, error) {
 	scanner := newScanner(pattern)
 	tokens := make([]token, 0, len(pattern)/2+1)
+	braceDepth := 0
 
 	for !scanner.eof() {
+		pos := scanner.position
 		switch scanner.char {
 		case '*':
 			if scanner.peekChar() == '*' {
 				scanner.readChar()
-				tokens = append(tokens, token{Type: tokenDoubleStar, Literal: "**"})
+				if scanner.peekChar() == '*' {
+					return nil, syntaxError(token{Literal: "***", Pos: pos}, "too many consecutive stars")
+				}
+				tokens = append(tokens, token{Type: tokenDoubleStar, Literal: "**", Pos: pos})
 			} else {
-				tokens = append(tokens, token{Type: tokenStar, Literal: "*"})
+				tokens = append(tokens, token{Type: tokenStar, Literal: "*", Pos: pos})
 			}
 		case '/':
-			tokens = append(tokens, token{Type: tokenSlash, Literal: "/"})
+			tokens = append(tokens, token{Type: tokenSlash, Literal: "/", Pos: pos})
 		case '.':
-			tokens = append(tokens, token{Type: tokenDot, Literal: "."})
+			tokens = append(tokens, token{Type: tokenDot, Literal: ".", Pos: pos})
 		case '!':
-			tokens = append(tokens, token{Type: tokenNegate, Literal: "!"})
+			if pos > 0 {
+				tokens = appendLiteral(tokens, "!", pos)
+				break
+			}
+			tokens = append(tokens, token{Type: tokenNegate, Literal: "!", Pos: pos})
+		case '?':
+			tokens = append(tokens, token{Type: tokenQuestion, Literal: "?", Pos: pos})
+		case '[':
+			end := scanClass(pattern, pos, !opts.noEscape)
+			if end == -1 {
+				return nil, syntaxError(token{Literal: "[", Pos: pos}, "unterminated character class")
+			}
+			tokens = append(tokens, token{Type: tokenClass, Literal: pattern[pos:end], Pos: pos})
+			for scanner.position < end-1 {
+				scanner.readChar()
+			}
+		case '{':
+			braceDepth++
+			tokens = append(tokens, token{Type: tokenBraceOpen, Literal: "{", Pos: pos})
+		case '}':
+			if braceDepth == 0 {
+				tokens = appendLiteral(tokens, "}", pos)
+				break
+			}
+			braceDepth--
+			tokens = append(tokens, token{Type: tokenBraceClose, Literal: "}", Pos: pos})
+		case ',':
+			if braceDepth == 0 {
+				tokens = appendLiteral(tokens, ",", pos)
+				break
+			}
+			tokens = append(tokens, token{Type: tokenComma, Literal: ",", Pos: pos})
+		case '\\':
+			if opts.noEscape {
+				tokens = append(tokens, token{Type: tokenSlash, Literal: "/", Pos: pos})
+				break
+			}
+			if scanner.readPosition >= len(pattern) {
+				return nil, syntaxError(token{Literal: `\`, Pos: pos}, "trailing backslash")
+			}
+			_, size := utf8.DecodeRuneInString(pattern[scanner.readPosition:])
+			tokens = appendLiteral(tokens, pattern[scanner.readPosition:scanner.readPosition+size], pos)
+			for i := 0; i < size; i++ {
+				scanner.readChar()
+			}
 		default:
 			if !isSpecialChar(scanner.char) {
-				startPos := scanner.position
 				for !scanner.eof() && !isSpecialChar(scanner.char) {
 					scanner.readChar()
 				}
-				literal := pattern[startPos:scanner.position]
-				tokens = append(tokens, token{Type: tokenLiteral, Literal: literal})
+				tokens = appendLiteral(tokens, pattern[pos:scanner.position], pos)
 				continue
 			}
 		}
@@ -35,3 +84,13 @@ func lex(pattern string) ([]token, error) {
 
 	return tokens, nil
 }
+
+// appendLiteral merges literal text into a preceding literal token so that
+// characters which are only special in some contexts do not split literals.
+func appendLiteral(tokens []token, literal string, pos int) []token {
+	if n := len(tokens); n > 0 && tokens[n-1].Type == tokenLiteral {
+		tokens[n-1].Literal += literal
+		return tokens
+	}
+	return append(tokens, token{Type: tokenLiteral, Literal: literal, Pos: pos})
+}
@@ -1,38 +1,127 @@
 package glob
 
-type matcher struct {
-	ast *node
+import "strings"
+
+// Matcher is implemented by anything that can decide whether a slash-separated
+// path matches. Patterns, pattern sets and custom predicates all satisfy it.
+type Matcher interface {
+	Match(path string) bool
+}
+
+// MatcherFunc adapts an ordinary function to the Matcher interface.
+type MatcherFunc func(path string) bool
+
+func (f MatcherFunc) Match(path string) bool {
+	return f(path)
+}
+
+// Not returns a Matcher that matches exactly the paths m does not.
+func Not(m Matcher) Matcher {
+	return MatcherFunc(func(path string) bool {
+		return !m.Match(path)
+	})
+}
+
+// Pattern is a compiled glob pattern. It is safe for concurrent use.
+type Pattern struct {
+	pattern string
+	ast     *node
+	negate  bool
 
 	isExactMatch   bool
 	isSimpleSuffix bool
+
+	captures     []*node
+	captureIndex map[*node]int
+	groups       []*node
 }
 
-func Matcher(pattern string) *matcher {
-	ast, err := compile(pattern)
+// Compile parses a glob pattern and returns a Pattern that can be used to
+// match paths. Malformed patterns are reported as a *SyntaxError.
+func Compile(pattern string, opts ...Option) (*Pattern, error) {
+	ast, negate, err := compile(pattern, opts...)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	matcher := &matcher{ast: ast}
+	p := &Pattern{pattern: pattern, ast: ast, negate: negate}
+	p.captureIndex = make(map[*node]int)
+	p.captures = captureIndex(ast, nil, nil, p.captureIndex)
+	p.groups = braceGroups(ast)
 	if ast.Type == tokenLiteral && ast.Next == nil {
-		matcher.isExactMatch = true
+		p.isExactMatch = true
 	}
 	if ast.Type == tokenStar && ast.Next != nil && ast.Next.Type == tokenLiteral && ast.Next.Next == nil {
-		matcher.isSimpleSuffix = true
+		p.isSimpleSuffix = true
+	}
+	return p, nil
+}
+
+// MustCompile is like Compile but panics if the pattern cannot be parsed.
+func MustCompile(pattern string, opts ...Option) *Pattern {
+	p, err := Compile(pattern, opts...)
+	if err != nil {
+		panic(err)
 	}
-	return matcher
+	return p
+}
+
+// String returns the source text of the pattern.
+func (m *Pattern) String() string {
+	return m.pattern
 }
 
-func (m *matcher) AST() *node {
+// AST returns the root node of the compiled pattern.
+func (m *Pattern) AST() *node {
 	return m.ast
 }
 
-func (m *matcher) Matches(path string) (bool, error) {
+// Match reports whether path matches the pattern.
+func (m *Pattern) Match(path string) bool {
+	ok, _ := m.Matches(path)
+	return ok
+}
+
+// CouldMatchPrefix reports whether any path inside the directory dir could
+// match the pattern. Walkers use it to avoid reading directories that cannot
+// contain matches. The root directory "." always could.
+func (m *Pattern) CouldMatchPrefix(dir string) bool {
+	if m.negate || dir == "." || dir == "" {
+		return true
+	}
+	return m.ast.CouldMatchPrefix(dir)
+}
+
+// MaxDepth returns the largest number of path segments a matching path can
+// have, or -1 if the pattern matches paths of any depth, as it does when it
+// contains "**" or is negated.
+func (m *Pattern) MaxDepth() int {
+	if m.negate {
+		return -1
+	}
+	slashes := m.ast.maxSlashes(nil)
+	if slashes < 0 {
+		return -1
+	}
+	return slashes + 1
+}
+
+// Negated reports whether the pattern starts with '!' and therefore matches
+// every path its remainder does not.
+func (m *Pattern) Negated() bool {
+	return m.negate
+}
+
+// Matches reports whether path matches the pattern.
+func (m *Pattern) Matches(path string) (bool, error) {
 	if m.isExactMatch {
-		return path == m.ast.Value, nil
+		return (path == m.ast.Value) != m.negate, nil
 	}
 	if m.isSimpleSuffix {
 		suffix := m.ast.Next.Value
-		return len(path) >= len(suffix) && path[len(path)-len(suffix):] == suffix, nil
+		match := len(path) >= len(suffix) && path[len(path)-len(suffix):] == suffix &&
+			strings.IndexByte(path[:len(path)-len(suffix)], '/') < 0
+		return match != m.negate, nil
 	}
-	return m.ast.MatchString(path, 0)
+	match, err := m.ast.MatchString(path, 0)
+	return match != m.negate, err
 }
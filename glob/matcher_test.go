@@ -1,7 +1,10 @@
 package glob
 
 import (
+	"errors"
+	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -25,6 +28,48 @@ func TestMatches(t *testing.T) {
 			path:    "other.txt",
 			want:    false,
 		},
+		{
+			name:    "simple suffix stays in segment",
+			pattern: "*file",
+			path:    "dir/myfile",
+			want:    false,
+		},
+		{
+			name:    "double wildcard matches zero directories at root",
+			pattern: "**/*.go",
+			path:    "main.go",
+			want:    true,
+		},
+		{
+			name:    "double wildcard matches zero directories in middle",
+			pattern: "x/b/**/y",
+			path:    "x/b/y",
+			want:    true,
+		},
+		{
+			name:    "double wildcard at end of brace matches zero directories",
+			pattern: "x/{a,b/**}/y",
+			path:    "x/b/y",
+			want:    true,
+		},
+		{
+			name:    "double wildcard at end of brace matches directories",
+			pattern: "x/{a,b/**}/y",
+			path:    "x/b/c/d/y",
+			want:    true,
+		},
+		{
+			name:    "double wildcard at end of brace needs separator",
+			pattern: "x/{a,b/**}/y",
+			path:    "x/by",
+			want:    false,
+		},
+		{
+			name:    "double wildcard zero directories only at segment start",
+			pattern: "x/b**/y",
+			path:    "x/y",
+			want:    false,
+		},
 		{
 			name:    "single wildcard",
 			pattern: "*.txt",
@@ -79,6 +124,18 @@ func TestMatches(t *testing.T) {
 			path:    "src/pkg/utils/test.go",
 			want:    true,
 		},
+		{
+			name:    "double wildcard matches zero leading segments",
+			pattern: "**/file.txt",
+			path:    "file.txt",
+			want:    true,
+		},
+		{
+			name:    "double wildcard matches zero middle segments",
+			pattern: "src/**/test.go",
+			path:    "src/test.go",
+			want:    true,
+		},
 		{
 			name:    "double wildcard middle no match",
 			pattern: "src/**/test.go",
@@ -145,6 +202,318 @@ func TestMatches(t *testing.T) {
 			path:    "src/a/b/main.go",
 			want:    false,
 		},
+		{
+			name:    "question mark",
+			pattern: "file?.log",
+			path:    "file1.log",
+			want:    true,
+		},
+		{
+			name:    "question mark requires one character",
+			pattern: "file?.log",
+			path:    "file.log",
+			want:    false,
+		},
+		{
+			name:    "question mark matches exactly one character",
+			pattern: "file?.log",
+			path:    "file12.log",
+			want:    false,
+		},
+		{
+			name:    "question mark does not match slash",
+			pattern: "a?b",
+			path:    "a/b",
+			want:    false,
+		},
+		{
+			name:    "question mark matches multibyte rune",
+			pattern: "caf?.txt",
+			path:    "café.txt",
+			want:    true,
+		},
+		{
+			name:    "question marks with double wildcard",
+			pattern: "**/log-??.txt",
+			path:    "var/log/log-01.txt",
+			want:    true,
+		},
+		{
+			name:    "character class",
+			pattern: "log-[0-9][0-9].txt",
+			path:    "log-42.txt",
+			want:    true,
+		},
+		{
+			name:    "character class no match",
+			pattern: "log-[0-9][0-9].txt",
+			path:    "log-4a.txt",
+			want:    false,
+		},
+		{
+			name:    "character class set",
+			pattern: "[abc].go",
+			path:    "b.go",
+			want:    true,
+		},
+		{
+			name:    "character class set no match",
+			pattern: "[abc].go",
+			path:    "d.go",
+			want:    false,
+		},
+		{
+			name:    "negated class with bang",
+			pattern: "[!0-9]*",
+			path:    "readme",
+			want:    true,
+		},
+		{
+			name:    "negated class with bang no match",
+			pattern: "[!0-9]*",
+			path:    "1readme",
+			want:    false,
+		},
+		{
+			name:    "negated class with caret",
+			pattern: "[^x].txt",
+			path:    "y.txt",
+			want:    true,
+		},
+		{
+			name:    "negated class with caret no match",
+			pattern: "[^x].txt",
+			path:    "x.txt",
+			want:    false,
+		},
+		{
+			name:    "class with literal closing bracket",
+			pattern: "[]a].txt",
+			path:    "].txt",
+			want:    true,
+		},
+		{
+			name:    "class with trailing dash",
+			pattern: "[a-].txt",
+			path:    "-.txt",
+			want:    true,
+		},
+		{
+			name:    "class does not match slash",
+			pattern: "a[!b]c",
+			path:    "a/c",
+			want:    false,
+		},
+		{
+			name:    "class with multibyte range",
+			pattern: "[à-ÿ].txt",
+			path:    "é.txt",
+			want:    true,
+		},
+		{
+			name:    "brace alternation",
+			pattern: "src/**/*.{go,mod,sum}",
+			path:    "src/pkg/go.mod",
+			want:    true,
+		},
+		{
+			name:    "brace alternation first branch",
+			pattern: "src/**/*.{go,mod,sum}",
+			path:    "src/pkg/main.go",
+			want:    true,
+		},
+		{
+			name:    "brace alternation no match",
+			pattern: "src/**/*.{go,mod,sum}",
+			path:    "src/pkg/main.rs",
+			want:    false,
+		},
+		{
+			name:    "nested brace alternation",
+			pattern: "{a,{b,c}}d",
+			path:    "cd",
+			want:    true,
+		},
+		{
+			name:    "nested brace alternation outer branch",
+			pattern: "{a,{b,c}}d",
+			path:    "ad",
+			want:    true,
+		},
+		{
+			name:    "nested brace alternation no match",
+			pattern: "{a,{b,c}}d",
+			path:    "dd",
+			want:    false,
+		},
+		{
+			name:    "brace alternation with empty branch",
+			pattern: "file{,.bak}",
+			path:    "file",
+			want:    true,
+		},
+		{
+			name:    "brace alternation with empty branch suffix",
+			pattern: "file{,.bak}",
+			path:    "file.bak",
+			want:    true,
+		},
+		{
+			name:    "brace alternation with wildcards",
+			pattern: "{src/*.go,**/*_test.go}",
+			path:    "pkg/a/x_test.go",
+			want:    true,
+		},
+		{
+			name:    "brace alternation with wildcards no match",
+			pattern: "{src/*.go,**/*_test.go}",
+			path:    "pkg/a/x.go",
+			want:    false,
+		},
+		{
+			name:    "comma outside braces is literal",
+			pattern: "a,b.txt",
+			path:    "a,b.txt",
+			want:    true,
+		},
+		{
+			name:    "closing brace outside braces is literal",
+			pattern: "a}.txt",
+			path:    "a}.txt",
+			want:    true,
+		},
+		{
+			name:    "escaped negation",
+			pattern: `\!important.txt`,
+			path:    "!important.txt",
+			want:    true,
+		},
+		{
+			name:    "escaped star",
+			pattern: `a\*b`,
+			path:    "a*b",
+			want:    true,
+		},
+		{
+			name:    "escaped star is not a wildcard",
+			pattern: `a\*b`,
+			path:    "axb",
+			want:    false,
+		},
+		{
+			name:    "escaped question mark",
+			pattern: `what\?.txt`,
+			path:    "what?.txt",
+			want:    true,
+		},
+		{
+			name:    "escaped bracket",
+			pattern: `\[draft].md`,
+			path:    "[draft].md",
+			want:    true,
+		},
+		{
+			name:    "escaped brace",
+			pattern: `\{a,b}`,
+			path:    "{a,b}",
+			want:    true,
+		},
+		{
+			name:    "escaped backslash",
+			pattern: `a\\b`,
+			path:    `a\b`,
+			want:    true,
+		},
+		{
+			name:    "escaped closing bracket in class",
+			pattern: `[\]].txt`,
+			path:    "].txt",
+			want:    true,
+		},
+		{
+			name:    "escaped dash in class is not a range",
+			pattern: `[a\-z]`,
+			path:    "-",
+			want:    true,
+		},
+		{
+			name:    "escaped dash in class no match",
+			pattern: `[a\-z]`,
+			path:    "m",
+			want:    false,
+		},
+		{
+			name:    "escaped range end in class",
+			pattern: `[+-\]]`,
+			path:    "]",
+			want:    true,
+		},
+		{
+			name:    "escaped backslash in class",
+			pattern: `[\\x]`,
+			path:    `\`,
+			want:    true,
+		},
+		{
+			name:    "negate exact match fast path",
+			pattern: "!file",
+			path:    "file",
+			want:    false,
+		},
+		{
+			name:    "negate exact match fast path different file",
+			pattern: "!file",
+			path:    "other",
+			want:    true,
+		},
+		{
+			name:    "negate suffix fast path",
+			pattern: "!*.js",
+			path:    "app.js",
+			want:    false,
+		},
+		{
+			name:    "negate suffix fast path different extension",
+			pattern: "!*.js",
+			path:    "app.ts",
+			want:    true,
+		},
+		{
+			name:    "bang in middle is literal",
+			pattern: "src/!foo",
+			path:    "src/!foo",
+			want:    true,
+		},
+		{
+			name:    "bang in middle does not negate",
+			pattern: "src/!foo",
+			path:    "src/bar",
+			want:    false,
+		},
+		{
+			name:    "bang after wildcard is literal",
+			pattern: "**/!important.txt",
+			path:    "notes/!important.txt",
+			want:    true,
+		},
+		{
+			name:    "bang inside braces is literal",
+			pattern: "{!a,b}",
+			path:    "!a",
+			want:    true,
+		},
+		{
+			name:    "double negation is negated literal bang",
+			pattern: "!!a",
+			path:    "!a",
+			want:    false,
+		},
+		{
+			name:    "double negation is negated literal bang different path",
+			pattern: "!!a",
+			path:    "a",
+			want:    true,
+		},
 		{
 			name:    "negate exact match",
 			pattern: "!file.txt",
@@ -209,7 +578,7 @@ func TestMatches(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			matcher := Matcher(tt.pattern)
+			matcher := MustCompile(tt.pattern)
 			got, err := matcher.Matches(tt.path)
 			if err != nil {
 				t.Fatalf("Matches() error = %v", err)
@@ -217,10 +586,379 @@ func TestMatches(t *testing.T) {
 			if got != tt.want {
 				t.Errorf("Matches() = %v, want %v, pattern: %s, path: %s", got, tt.want, tt.pattern, tt.path)
 			}
+			if _, ok := matcher.MatchCaptures(tt.path); ok != tt.want {
+				t.Errorf("MatchCaptures() ok = %v, want %v, pattern: %s, path: %s", ok, tt.want, tt.pattern, tt.path)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		offset  int
+		token   string
+	}{
+		{name: "lone negation", pattern: "!", offset: 0, token: "!"},
+		{name: "triple star", pattern: "src/***", offset: 4, token: "***"},
+		{name: "unterminated class", pattern: "log-[0-9", offset: 4, token: "["},
+		{name: "class closed only by escaped bracket", pattern: `[a\]`, offset: 0, token: "["},
+		{name: "invalid range", pattern: "a[z-a]", offset: 1, token: "[z-a]"},
+		{name: "unclosed brace", pattern: "*.{go,mod", offset: 2, token: "{"},
+		{name: "trailing backslash", pattern: `dir\`, offset: 3, token: `\`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Compile(tt.pattern)
+			if err == nil {
+				t.Fatalf("Compile() = %v, want error", p)
+			}
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Compile() error = %T, want *SyntaxError", err)
+			}
+			if syntaxErr.Offset != tt.offset || syntaxErr.Token != tt.token || syntaxErr.Pattern != tt.pattern {
+				t.Errorf("Compile() error = %+v, want offset %d, token %q", syntaxErr, tt.offset, tt.token)
+			}
+		})
+	}
+}
+
+func TestMustCompilePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustCompile() did not panic")
+		}
+	}()
+	MustCompile("{a,b")
+}
+
+func TestMatcherInterface(t *testing.T) {
+	matchers := []struct {
+		name    string
+		matcher Matcher
+		path    string
+		want    bool
+	}{
+		{name: "pattern", matcher: MustCompile("*.go"), path: "main.go", want: true},
+		{name: "fs pattern", matcher: FSMatcher("**/*.go"), path: "cmd/main.go", want: true},
+		{name: "func", matcher: MatcherFunc(func(path string) bool { return len(path) > 3 }), path: "main.go", want: true},
+		{name: "not", matcher: Not(MustCompile("*.go")), path: "main.go", want: false},
+		{name: "not no match", matcher: Not(MustCompile("*.go")), path: "main.rs", want: true},
+	}
+
+	for _, tt := range matchers {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.matcher.Match(tt.path); got != tt.want {
+				t.Errorf("Match() = %v, want %v, path: %s", got, tt.want, tt.path)
+			}
+		})
+	}
+}
+
+func TestCouldMatchPrefix(t *testing.T) {
+	tests := []struct {
+		pattern string
+		dir     string
+		want    bool
+	}{
+		{pattern: "src/pkg/*.go", dir: "src", want: true},
+		{pattern: "src/pkg/*.go", dir: "src/pkg", want: true},
+		{pattern: "src/pkg/*.go", dir: "src/pkg/internal", want: false},
+		{pattern: "src/pkg/*.go", dir: "lib", want: false},
+		{pattern: "src/*/main.go", dir: "src/cmd", want: true},
+		{pattern: "src/*/main.go", dir: "src/cmd/sub", want: false},
+		{pattern: "*.go", dir: "src", want: false},
+		{pattern: "**/*.go", dir: "a/b/c", want: true},
+		{pattern: "src/**", dir: "src/a/b", want: true},
+		{pattern: "src/**", dir: "lib", want: false},
+		{pattern: "{src,lib}/*.go", dir: "lib", want: true},
+		{pattern: "{src,lib}/*.go", dir: "test", want: false},
+		{pattern: "log-[0-9]/*.txt", dir: "log-1", want: true},
+		{pattern: "log-[0-9]/*.txt", dir: "log-a", want: false},
+		{pattern: "!src/*.go", dir: "lib", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.dir, func(t *testing.T) {
+			if got := MustCompile(tt.pattern).CouldMatchPrefix(tt.dir); got != tt.want {
+				t.Errorf("CouldMatchPrefix(%q) = %v, want %v, pattern: %s", tt.dir, got, tt.want, tt.pattern)
+			}
+		})
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    int
+	}{
+		{pattern: "*.go", want: 1},
+		{pattern: "src/*.go", want: 2},
+		{pattern: "src/*/main.go", want: 3},
+		{pattern: "{a,b/c}/*.go", want: 3},
+		{pattern: "{a,{b/c,d/e/f}}", want: 3},
+		{pattern: "**/*.go", want: -1},
+		{pattern: "src/{a,**}/x", want: -1},
+		{pattern: "!src/*.go", want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if got := MustCompile(tt.pattern).MaxDepth(); got != tt.want {
+				t.Errorf("MaxDepth() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatternSet(t *testing.T) {
+	patterns := []string{
+		"src/main.go",
+		"*.go",
+		"**/*.go",
+		"**/*_test.go",
+		"src/**/*.go",
+		"src/*.go",
+		"*file",
+		"docs/{a,b}/*.md",
+		"log-[0-9].txt",
+		"!*.go",
+		"**",
+		"",
+		"src/main.go",
+		"?.go",
+		"build/**",
+	}
+	paths := []string{
+		"",
+		"main.go",
+		"a.go",
+		"src/main.go",
+		"src/pkg/util_test.go",
+		"dir/myfile",
+		"myfile",
+		"docs/a/readme.md",
+		"docs/c/readme.md",
+		"log-1.txt",
+		"build/out/bin",
+		"README",
+		".go",
+	}
+
+	set := MustCompileSet(patterns...)
+	if set.Len() != len(patterns) {
+		t.Fatalf("Len() = %d, want %d", set.Len(), len(patterns))
+	}
+	for _, path := range paths {
+		var want []int
+		for i, pattern := range patterns {
+			if MustCompile(pattern).Match(path) {
+				want = append(want, i)
+			}
+		}
+
+		got := set.MatchIndices(path)
+		if !slices.Equal(got, want) {
+			t.Errorf("MatchIndices(%q) = %v, want %v", path, got, want)
+		}
+		if set.Match(path) != (len(want) > 0) {
+			t.Errorf("Match(%q) = %v, want %v", path, set.Match(path), len(want) > 0)
+		}
+	}
+
+	if _, err := CompileSet([]string{"*.go", "[a-"}); err == nil {
+		t.Error("CompileSet() error = nil, want syntax error")
+	}
+}
+
+func TestPatternSetCouldMatchPrefix(t *testing.T) {
+	set := MustCompileSet("src/*.go", "docs/**/*.md")
+	for dir, want := range map[string]bool{"src": true, "docs/api": true, "build": false, "src/pkg": false} {
+		if got := set.CouldMatchPrefix(dir); got != want {
+			t.Errorf("CouldMatchPrefix(%q) = %v, want %v", dir, got, want)
+		}
+	}
+}
+
+func TestRuleSet(t *testing.T) {
+	rules := []string{"src/**/*.ts", "!**/*.test.ts", "src/legacy/**", "!src/legacy/vendor/**", "\\!bang.ts"}
+
+	tests := []struct {
+		path     string
+		policy   RulePolicy
+		want     bool
+		wantRule int
+	}{
+		{path: "src/app/main.ts", policy: LastMatchWins, want: true, wantRule: 0},
+		{path: "src/app/main.test.ts", policy: LastMatchWins, want: false, wantRule: 1},
+		{path: "src/legacy/old.test.ts", policy: LastMatchWins, want: true, wantRule: 2},
+		{path: "src/legacy/vendor/lib.ts", policy: LastMatchWins, want: false, wantRule: 3},
+		{path: "docs/readme.md", policy: LastMatchWins, want: false, wantRule: -1},
+		{path: "!bang.ts", policy: LastMatchWins, want: true, wantRule: 4},
+		{path: "src/app/main.ts", policy: ExcludeWins, want: true, wantRule: 0},
+		{path: "src/legacy/old.test.ts", policy: ExcludeWins, want: false, wantRule: 1},
+		{path: "src/legacy/readme.md", policy: ExcludeWins, want: true, wantRule: 2},
+		{path: "src/legacy/vendor/lib.ts", policy: ExcludeWins, want: false, wantRule: 3},
+		{path: "docs/readme.md", policy: ExcludeWins, want: false, wantRule: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d/%s", tt.policy, tt.path), func(t *testing.T) {
+			set := MustCompileRules(rules...).WithPolicy(tt.policy)
+			got, rule := set.Decide(tt.path)
+			if got != tt.want {
+				t.Errorf("Decide(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+			gotRule := -1
+			if rule != nil {
+				gotRule = rule.Index
+			}
+			if gotRule != tt.wantRule {
+				t.Errorf("Decide(%q) rule = %d, want %d", tt.path, gotRule, tt.wantRule)
+			}
+			if set.Match(tt.path) != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.path, !tt.want, tt.want)
+			}
+		})
+	}
+
+	if _, err := CompileRules([]string{"src/**", "![a-"}); err == nil {
+		t.Error("CompileRules() error = nil, want syntax error")
+	}
+}
+
+func TestMatchCaptures(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    []string
+		wantOK  bool
+	}{
+		{pattern: "src/**/*.test.js", path: "src/a/b/foo.test.js", want: []string{"a/b", "foo"}, wantOK: true},
+		{pattern: "src/**/*.test.js", path: "src/foo.test.js", want: []string{"", "foo"}, wantOK: true},
+		{pattern: "**/*.go", path: "main.go", want: []string{"", "main"}, wantOK: true},
+		{pattern: "src/**", path: "src/a/b", want: []string{"a/b"}, wantOK: true},
+		{pattern: "*.*", path: "archive.tar.gz", want: []string{"archive.tar", "gz"}, wantOK: true},
+		{pattern: "file?.[a-z]og", path: "file1.log", want: []string{"1", "l"}, wantOK: true},
+		{pattern: "*.{ts,tsx}", path: "app.tsx", want: []string{"app"}, wantOK: true},
+		{pattern: "{src/*,lib}/x", path: "src/a/x", want: []string{"a"}, wantOK: true},
+		{pattern: "{src/*,lib}/x", path: "lib/x", want: []string{""}, wantOK: true},
+		{pattern: "{*.go,?.md}", path: "a.md", want: []string{"", "a"}, wantOK: true},
+		{pattern: "x/{a,b/**}/*.go", path: "x/b/main.go", want: []string{"", "main"}, wantOK: true},
+		{pattern: "a{,b}c", path: "ac", want: []string{}, wantOK: true},
+		{pattern: "docs/*.md", path: "docs/readme.md", want: []string{"readme"}, wantOK: true},
+		{pattern: "docs/*.md", path: "docs/a/readme.md", wantOK: false},
+		{pattern: "exact.txt", path: "exact.txt", want: []string{}, wantOK: true},
+		{pattern: "!*.go", path: "main.rs", wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.path, func(t *testing.T) {
+			got, ok := MustCompile(tt.pattern).MatchCaptures(tt.path)
+			if ok != tt.wantOK {
+				t.Fatalf("MatchCaptures() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("MatchCaptures() = %q, want %q", got, tt.want)
+			}
 		})
 	}
 }
 
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		from    string
+		to      string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{from: "src/**/*.ts", to: "dist/**/*.js", path: "src/a/b/x.ts", want: "dist/a/b/x.js"},
+		{from: "src/**/*.ts", to: "dist/**/*.js", path: "src/x.ts", want: "dist/x.js"},
+		{from: "**/*.ts", to: "**/*.js", path: "x.ts", want: "x.js"},
+		{from: "src/**", to: "dist/**", path: "src/a/b", want: "dist/a/b"},
+		{from: "*.{ts,tsx}", to: "out/*.js", path: "app.tsx", want: "out/app.js"},
+		{from: "src/*.{ts,tsx}", to: "dist/*.{js,jsx}", path: "src/a.ts", want: "dist/a.js"},
+		{from: "src/*.{ts,tsx}", to: "dist/*.{js,jsx}", path: "src/a.tsx", want: "dist/a.jsx"},
+		{from: "src/{app,lib}/**/*.ts", to: "dist/**/*.js", path: "src/app/x/y.ts", want: "dist/x/y.js"},
+		{from: "src/{app,lib}/**/*.ts", to: "{web,pkg}/**/*.js", path: "src/lib/y.ts", want: "pkg/y.js"},
+		{from: "{a,b/*}/*.go", to: "out/*.go", path: "b/x/y.go", want: "out/y.go"},
+		{from: "img-?.png", to: "thumb-*.jpg", path: "img-3.png", wantErr: true},
+		{from: "a/?.ts", to: "b/[xy].js", path: "a/q.ts", wantErr: true},
+		{from: "a/?.ts", to: "b/?.js", path: "a/q.ts", wantErr: true},
+		{from: "*.{ts,tsx}", to: "*.{js,jsx,mjs}", path: "a.ts", wantErr: true},
+		{from: "*.{ts,tsx}", to: "*.{js,*.jsx}", path: "a.ts", wantErr: true},
+		{from: "*.ts", to: "*.{js,jsx}", path: "a.ts", wantErr: true},
+		{from: "*.md", to: `\*\{*\}.html`, path: "readme.md", want: "*{readme}.html"},
+		{from: "src/**/*.ts", to: "dist/**/*.js", path: "lib/x.ts", wantErr: true},
+		{from: "src/*.ts", to: "dist/**/*.js", path: "src/x.ts", wantErr: true},
+		{from: "src/**/*.ts", to: "dist/*/*.js", path: "src/x.ts", wantErr: true},
+		{from: "!*.ts", to: "*.js", path: "x.js", wantErr: true},
+		{from: "[a-", to: "*.js", path: "x.js", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			got, err := Rewrite(tt.from, tt.to, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Rewrite() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Rewrite() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Rewrite("*.ts", "*.js", "a/b.ts"); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("Rewrite() error = %v, want ErrNoMatch", err)
+	}
+}
+
+func TestEscapeRoundTrip(t *testing.T) {
+	names := []string{
+		"!important.txt",
+		"a*b",
+		"what?.txt",
+		"[draft] notes.md",
+		"{a,b}.txt",
+		`back\slash`,
+		"src/main.go",
+	}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			matcher := MustCompile(Escape(name))
+			got, err := matcher.Matches(name)
+			if err != nil {
+				t.Fatalf("Matches() error = %v", err)
+			}
+			if !got {
+				t.Errorf("Matches() = false for escaped pattern %s, path: %s", Escape(name), name)
+			}
+		})
+	}
+}
+
+func TestNoEscape(t *testing.T) {
+	matcher := MustCompile(`src\*.go`, NoEscape())
+	got, err := matcher.Matches("src/main.go")
+	if err != nil {
+		t.Fatalf("Matches() error = %v", err)
+	}
+	if !got {
+		t.Errorf("Matches() = false, want true for backslash separator")
+	}
+
+	// Without escaping, a backslash in a class is an ordinary character.
+	class := MustCompile(`[\]].txt`, NoEscape())
+	if !class.Match(`\].txt`) || class.Match("].txt") {
+		t.Errorf(`[\]].txt with NoEscape should match \].txt and not ].txt`)
+	}
+}
+
 // globToRegex converts a glob pattern to a regex pattern
 func globToRegex(pattern string) *regexp.Regexp {
 	pattern = regexp.QuoteMeta(pattern)
@@ -281,7 +1019,7 @@ var benchmarkCases = []struct {
 func BenchmarkGlobMatcher(b *testing.B) {
 	for _, bc := range benchmarkCases {
 		b.Run(bc.name, func(b *testing.B) {
-			matcher := Matcher(bc.pattern)
+			matcher := MustCompile(bc.pattern)
 			b.ResetTimer()
 			for i := 0; i < b.N; i++ {
 				_, _ = matcher.Matches(bc.path)
@@ -306,7 +1044,7 @@ func BenchmarkGlobCompilation(b *testing.B) {
 	for _, bc := range benchmarkCases {
 		b.Run(bc.name, func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
-				_ = Matcher(bc.pattern)
+				_ = MustCompile(bc.pattern)
 			}
 		})
 	}
@@ -326,7 +1064,7 @@ func BenchmarkGlobMatcherWithCompilation(b *testing.B) {
 	for _, bc := range benchmarkCases {
 		b.Run(bc.name, func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
-				matcher := Matcher(bc.pattern)
+				matcher := MustCompile(bc.pattern)
 				_, _ = matcher.Matches(bc.path)
 			}
 		})
@@ -343,3 +1081,59 @@ func BenchmarkRegexMatcherWithCompilation(b *testing.B) {
 		})
 	}
 }
+
+// setBenchmarkPatterns mimics the include globs of a large build: many file
+// extensions, per-package trees and generated outputs.
+func setBenchmarkPatterns(n int) []string {
+	var patterns []string
+	for i := 0; len(patterns) < n; i++ {
+		patterns = append(patterns,
+			fmt.Sprintf("**/*.ext%d", i),
+			fmt.Sprintf("src/pkg%d/**/*.go", i),
+			fmt.Sprintf("docs/page%d.md", i),
+			fmt.Sprintf("build/*/out%d/*.o", i),
+		)
+	}
+	return patterns[:n]
+}
+
+var setBenchmarkPaths = []string{
+	"src/pkg42/internal/handler.go",
+	"assets/images/logo.ext7",
+	"docs/page3.md",
+	"build/linux/out12/main.o",
+	"vendor/github.com/x/y/z.rs",
+}
+
+func BenchmarkPatternSet(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("Set/%d", n), func(b *testing.B) {
+			set := MustCompileSet(setBenchmarkPatterns(n)...)
+			var dst []int
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, path := range setBenchmarkPaths {
+					dst = set.AppendMatchIndices(dst[:0], path)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("Loop/%d", n), func(b *testing.B) {
+			var patterns []*Pattern
+			for _, pattern := range setBenchmarkPatterns(n) {
+				patterns = append(patterns, MustCompile(pattern))
+			}
+			var dst []int
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, path := range setBenchmarkPaths {
+					dst = dst[:0]
+					for j, p := range patterns {
+						if p.Match(path) {
+							dst = append(dst, j)
+						}
+					}
+				}
+			}
+		})
+	}
+}
@@ -4,13 +4,14 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"unicode/utf8"
 )
 
 type node struct {
-	Type   tokenType
-	Value  string
-	Next   *node
-	Negate bool
+	Type  tokenType
+	Value string
+	Next  *node
+	Class *charClass
 
 	Children []*node
 }
@@ -20,17 +21,47 @@ func (n *node) String() string {
 }
 
 func (n *node) Tree() string {
+	return n.tree(nil)
+}
+
+func (n *node) tree(stop *node) string {
 	sb := ""
-	sb += fmt.Sprintf("- %s\n", n.String())
-	for _, child := range n.Children {
-		sb += "  " + child.Tree()
-	}
-	if n.Next != nil {
-		sb += n.Next.Tree()
+	for current := n; current != nil && current != stop; current = current.Next {
+		sb += fmt.Sprintf("- %s\n", current.String())
+		for _, child := range current.Children {
+			sb += "  " + child.tree(current.Next)
+		}
 	}
 	return sb
 }
 
+// maxSlashes returns the largest number of '/' a path matched by the nodes
+// from n up to stop can contain, or -1 if there is no limit.
+func (n *node) maxSlashes(stop *node) int {
+	count := 0
+	for current := n; current != nil && current != stop; current = current.Next {
+		switch current.Type {
+		case tokenDoubleStar:
+			return -1
+		case tokenSlash:
+			count++
+		case tokenLiteral:
+			count += strings.Count(current.Value, "/")
+		case tokenBraceOpen:
+			best := 0
+			for _, child := range current.Children {
+				slashes := child.maxSlashes(current.Next)
+				if slashes < 0 {
+					return -1
+				}
+				best = max(best, slashes)
+			}
+			count += best
+		}
+	}
+	return count
+}
+
 type matchResult struct {
 	node *node
 	pos  int
@@ -44,14 +75,20 @@ var stackPool = sync.Pool{
 }
 
 func (n *node) MatchString(path string, pos int) (bool, error) {
-	if n.Negate {
-		match, err := n.matchWithoutNegate(path, pos)
-		return !match, err
-	}
-	return n.matchWithoutNegate(path, pos)
+	return n.match(path, pos, false), nil
+}
+
+// CouldMatchPrefix reports whether any path below the directory dir could
+// match. It is conservative: false means no descendant can match, true means
+// one might.
+func (n *node) CouldMatchPrefix(dir string) bool {
+	return n.match(dir+"/", 0, true)
 }
 
-func (n *node) matchWithoutNegate(path string, pos int) (bool, error) {
+// match runs the matching engine. In partial mode it succeeds as soon as the
+// whole input has been consumed while pattern nodes remain, which means some
+// longer path starting with the input could still match.
+func (n *node) match(path string, pos int, partial bool) bool {
 	stackPtr := stackPool.Get().(*[]matchResult)
 	stack := (*stackPtr)[:0]
 	stack = append(stack, matchResult{node: n, pos: pos})
@@ -72,10 +109,16 @@ func (n *node) matchWithoutNegate(path string, pos int) (bool, error) {
 			if i > len(path) {
 				break nodeLoop
 			}
+			if partial && i == len(path) {
+				return true
+			}
 
 			switch current.Type {
 			case tokenLiteral:
 				if !matchLiteral(path, i, current.Value) {
+					if partial && strings.HasPrefix(current.Value, path[i:]) {
+						return true
+					}
 					break nodeLoop
 				}
 				i += len(current.Value)
@@ -92,6 +135,23 @@ func (n *node) matchWithoutNegate(path string, pos int) (bool, error) {
 				}
 				i++
 
+			case tokenQuestion:
+				if i >= len(path) || path[i] == '/' {
+					break nodeLoop
+				}
+				_, size := utf8.DecodeRuneInString(path[i:])
+				i += size
+
+			case tokenClass:
+				if i >= len(path) || path[i] == '/' {
+					break nodeLoop
+				}
+				r, size := utf8.DecodeRuneInString(path[i:])
+				if !current.Class.matches(r) {
+					break nodeLoop
+				}
+				i += size
+
 			case tokenStar:
 				if current.Next == nil {
 					hasSlashInRemainder := false
@@ -102,7 +162,7 @@ func (n *node) matchWithoutNegate(path string, pos int) (bool, error) {
 						}
 					}
 					if !hasSlashInRemainder {
-						return true, nil
+						return true
 					}
 					break nodeLoop
 				}
@@ -119,18 +179,31 @@ func (n *node) matchWithoutNegate(path string, pos int) (bool, error) {
 
 			case tokenDoubleStar:
 				if current.Next == nil {
-					return true, nil
+					return true
 				}
 
-				if match, _ := current.Next.MatchString(path, i); match {
-					return true, nil
+				if current.Next.match(path, i, partial) {
+					return true
 				}
 
 				for j := len(path); j > i; j-- {
 					stack = append(stack, matchResult{node: current.Next, pos: j})
 				}
+
+				// "**/" at the start of a segment may also match zero segments.
+				if slash := slashAfter(current); slash != nil && (i == 0 || path[i-1] == '/') {
+					stack = append(stack, matchResult{node: slash.Next, pos: i})
+				}
 				break nodeLoop
 
+			case tokenBraceOpen:
+				for j := len(current.Children) - 1; j >= 0; j-- {
+					stack = append(stack, matchResult{node: current.Children[j], pos: i})
+				}
+				break nodeLoop
+
+			case tokenBraceClose:
+
 			default:
 				break nodeLoop
 			}
@@ -139,11 +212,25 @@ func (n *node) matchWithoutNegate(path string, pos int) (bool, error) {
 		}
 
 		if current == nil && i == len(path) {
-			return true, nil
+			return true
 		}
 	}
 
-	return false, nil
+	return false
+}
+
+// slashAfter returns the '/' node that directly follows n, looking through
+// the ends of brace groups, or nil if there is none. It lets "{a,b/**}/c"
+// match "b/c" just as "b/**/c" does.
+func slashAfter(n *node) *node {
+	next := n.Next
+	for next != nil && next.Type == tokenBraceClose {
+		next = next.Next
+	}
+	if next != nil && next.Type == tokenSlash {
+		return next
+	}
+	return nil
 }
 
 //go:inline
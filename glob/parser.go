@@ -1,37 +1,107 @@
 package glob
 
-func parse(tokens []token) (*node, error) {
+type parser struct {
+	tokens []token
+	pos    int
+	escape bool
+}
+
+// parse builds the AST for tokens. A leading negation applies to the whole
+// pattern and is reported separately rather than stored on a node.
+func parse(tokens []token, opts options) (*node, bool, error) {
+	p := &parser{tokens: tokens, escape: !opts.noEscape}
+
+	negate := false
+	if len(tokens) > 0 && tokens[0].Type == tokenNegate {
+		if len(tokens) == 1 {
+			return nil, false, syntaxError(tokens[0], "negation without pattern")
+		}
+		negate = true
+		p.pos++
+	}
+
+	head, _, err := p.parseSequence()
+	if err != nil {
+		return nil, false, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, false, syntaxError(p.tokens[p.pos], "unexpected %q", p.tokens[p.pos].Literal)
+	}
+	return head, negate, nil
+}
+
+// parseSequence parses nodes up to the end of the input, a comma or a closing
+// brace. It returns the first node and the node whose Next must be linked to
+// whatever follows the sequence.
+func (p *parser) parseSequence() (*node, *node, error) {
 	var firstNode *node
 	var lastNode *node
 
-	for i := 0; i < len(tokens); i++ {
-		token := tokens[i]
+	for ; p.pos < len(p.tokens); p.pos++ {
+		token := p.tokens[p.pos]
+		if token.Type == tokenComma || token.Type == tokenBraceClose {
+			break
+		}
 
-		negate := false
-		if token.Type == tokenNegate {
-			negate = true
-			i++
-			if i >= len(tokens) {
-				break
+		var head, tail *node
+		switch token.Type {
+		case tokenBraceOpen:
+			alt, end, err := p.parseAlternation(token)
+			if err != nil {
+				return nil, nil, err
 			}
-			token = tokens[i]
+			head, tail = alt, end
+		case tokenClass:
+			class, err := parseClass(token.Literal, p.escape)
+			if err != nil {
+				return nil, nil, syntaxError(token, "%s", err.Error())
+			}
+			head = &node{Type: token.Type, Value: token.Literal, Class: class}
+			tail = head
+		default:
+			head = &node{Type: token.Type, Value: token.Literal}
+			tail = head
 		}
 
-		node := &node{
-			Type:   token.Type,
-			Value:  token.Literal,
-			Negate: negate,
-		}
 		if firstNode == nil {
-			firstNode = node
+			firstNode = head
 		}
 
 		if lastNode != nil {
-			lastNode.Next = node
+			lastNode.Next = head
 		}
 
-		lastNode = node
+		lastNode = tail
 	}
 
-	return firstNode, nil
+	return firstNode, lastNode, nil
+}
+
+// parseAlternation parses a brace group starting at the current '{' token.
+// Every branch is linked to a shared closing node, which is returned so the
+// caller can attach the rest of the pattern to it.
+func (p *parser) parseAlternation(open token) (*node, *node, error) {
+	end := &node{Type: tokenBraceClose, Value: "}"}
+	alt := &node{Type: tokenBraceOpen, Value: "{", Next: end}
+
+	for {
+		p.pos++
+		head, tail, err := p.parseSequence()
+		if err != nil {
+			return nil, nil, err
+		}
+		if head == nil {
+			alt.Children = append(alt.Children, end)
+		} else {
+			tail.Next = end
+			alt.Children = append(alt.Children, head)
+		}
+
+		if p.pos >= len(p.tokens) {
+			return nil, nil, syntaxError(open, "unclosed brace")
+		}
+		if p.tokens[p.pos].Type == tokenBraceClose {
+			return alt, end, nil
+		}
+	}
 }
@@ -0,0 +1,180 @@
+package glob
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoMatch is returned by Rewrite when the path does not match the source
+// pattern.
+var ErrNoMatch = errors.New("glob: path does not match pattern")
+
+// Rewriter maps paths matching one pattern onto another, carrying over the
+// text captured by each wildcard. It is safe for concurrent use.
+type Rewriter struct {
+	from  *Pattern
+	parts []templatePart
+}
+
+// templatePart is one node of a compiled template. Literal, dot and slash
+// nodes hold their text. Star and double star nodes hold the index of the
+// source capture they copy, and brace groups hold the index of the source
+// brace group whose matched alternative picks one of alts.
+type templatePart struct {
+	kind  tokenType
+	text  string
+	index int
+	alts  []string
+}
+
+// NewRewriter compiles a source pattern and a target template. Only "*" and
+// "**" in the template take text from the source: they are filled in order
+// with the top-level "*" and "**" of the source, so they must be of the same
+// kinds in the same order, although the template may leave out trailing
+// ones. Each top-level brace group of the template pairs with the source's
+// brace group at the same position, must list the same number of fixed
+// alternatives, and yields the one at the index the source matched, so
+// "*.{ts,tsx}" rewrites to "*.{js,jsx}". Templates cannot use "?" or
+// character classes, and neither pattern may be negated.
+func NewRewriter(from, to string, opts ...Option) (*Rewriter, error) {
+	source, err := Compile(from, opts...)
+	if err != nil {
+		return nil, err
+	}
+	target, err := Compile(to, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if source.negate {
+		return nil, fmt.Errorf("glob: cannot rewrite from negated pattern %q", from)
+	}
+	if target.negate {
+		return nil, fmt.Errorf("glob: cannot rewrite to negated pattern %q", to)
+	}
+
+	var wildcards []*node
+	for n := source.ast; n != nil; n = n.Next {
+		if n.Type == tokenStar || n.Type == tokenDoubleStar {
+			wildcards = append(wildcards, n)
+		}
+	}
+
+	var parts []templatePart
+	used, groups := 0, 0
+	for n := target.ast; n != nil; n = n.Next {
+		switch n.Type {
+		case tokenLiteral:
+			parts = append(parts, templatePart{kind: tokenLiteral, text: n.Value})
+		case tokenDot:
+			parts = append(parts, templatePart{kind: tokenLiteral, text: "."})
+		case tokenSlash:
+			parts = append(parts, templatePart{kind: tokenSlash, text: "/"})
+
+		case tokenStar, tokenDoubleStar:
+			i := used
+			if i >= len(wildcards) {
+				return nil, fmt.Errorf("glob: rewrite target %q has more wildcards than source %q", to, from)
+			}
+			if want := wildcards[i]; n.Type != want.Type {
+				return nil, fmt.Errorf("glob: wildcard %d of rewrite target %q is %s but %s in source %q",
+					i+1, to, describeWildcard(n), describeWildcard(want), from)
+			}
+			parts = append(parts, templatePart{kind: n.Type, index: source.captureIndex[wildcards[i]]})
+			used++
+
+		case tokenBraceOpen:
+			if groups >= len(source.groups) {
+				return nil, fmt.Errorf("glob: brace group %d of rewrite target %q has no counterpart in source %q",
+					groups+1, to, from)
+			}
+			if got, want := len(n.Children), len(source.groups[groups].Children); got != want {
+				return nil, fmt.Errorf("glob: brace group %d of rewrite target %q has %d alternatives but %d in source %q",
+					groups+1, to, got, want, from)
+			}
+			alts := make([]string, len(n.Children))
+			for i, child := range n.Children {
+				text, rest := literalPrefix(child)
+				if rest != n.Next {
+					return nil, fmt.Errorf("glob: brace group %d of rewrite target %q must only list fixed text",
+						groups+1, to)
+				}
+				alts[i] = text
+			}
+			parts = append(parts, templatePart{kind: tokenBraceOpen, index: groups, alts: alts})
+			groups++
+			n = n.Next
+
+		default:
+			return nil, fmt.Errorf("glob: rewrite target %q cannot use %s; only \"*\" and \"**\" are filled from the source",
+				to, describeWildcard(n))
+		}
+	}
+	return &Rewriter{from: source, parts: parts}, nil
+}
+
+func describeWildcard(n *node) string {
+	switch n.Type {
+	case tokenStar:
+		return `"*"`
+	case tokenDoubleStar:
+		return `"**"`
+	case tokenQuestion:
+		return `"?"`
+	default:
+		return "a character class"
+	}
+}
+
+// Rewrite returns the target path for path, or false if path does not match
+// the source pattern. A "**" that captured nothing drops the slash that
+// follows it in the template, so "dist/**/*.js" yields "dist/x.js" rather
+// than "dist//x.js".
+func (r *Rewriter) Rewrite(path string) (string, bool) {
+	c, ok := r.from.capture(path)
+	if !ok {
+		return "", false
+	}
+
+	var sb strings.Builder
+	skipSlash := false
+	for _, part := range r.parts {
+		if part.kind == tokenSlash && skipSlash {
+			skipSlash = false
+			continue
+		}
+		skipSlash = false
+
+		switch part.kind {
+		case tokenStar, tokenDoubleStar:
+			start, end := c.spans[2*part.index], c.spans[2*part.index+1]
+			sb.WriteString(path[start:end])
+			skipSlash = part.kind == tokenDoubleStar && start == end
+		case tokenBraceOpen:
+			sb.WriteString(part.alts[c.alts[part.index]])
+		default:
+			sb.WriteString(part.text)
+		}
+	}
+
+	out := sb.String()
+	if skipSlash {
+		out = strings.TrimSuffix(out, "/")
+	}
+	return out, true
+}
+
+// Rewrite rewrites path from the pattern from to the template to, as
+// described on NewRewriter. It returns ErrNoMatch if path does not match
+// from. Use a Rewriter to rewrite many paths with the same patterns.
+func Rewrite(from, to, path string) (string, error) {
+	r, err := NewRewriter(from, to)
+	if err != nil {
+		return "", err
+	}
+	out, ok := r.Rewrite(path)
+	if !ok {
+		return "", ErrNoMatch
+	}
+	return out, nil
+}
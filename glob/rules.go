@@ -0,0 +1,146 @@
+package glob
+
+// RulePolicy decides which rule of a RuleSet wins when several match.
+type RulePolicy int
+
+const (
+	// LastMatchWins lets the last matching rule decide, as in .gitignore
+	// files. This is the default.
+	LastMatchWins RulePolicy = iota
+	// ExcludeWins excludes a path as soon as any exclude rule matches it,
+	// wherever that rule appears, as tsconfig "exclude" lists do.
+	ExcludeWins
+)
+
+// Rule is a single include or exclude rule of a RuleSet.
+type Rule struct {
+	Index   int    // position in the rule list
+	Text    string // the rule as written
+	Exclude bool   // the rule starts with '!' and excludes paths
+
+	pattern *Pattern
+}
+
+// Pattern returns the rule's pattern, without the leading '!' of exclude
+// rules.
+func (r *Rule) Pattern() *Pattern {
+	return r.pattern
+}
+
+// RuleSet is an ordered list of include and exclude rules. Rules starting
+// with '!' exclude the paths they match; a literal leading '!' is written
+// "\!". A path is included when the deciding rule is an include rule, so paths
+// no rule matches are not included. It is safe for concurrent use.
+type RuleSet struct {
+	rules  []Rule
+	policy RulePolicy
+}
+
+// CompileRules compiles an ordered rule list. The first malformed rule is
+// reported as a *SyntaxError.
+func CompileRules(rules []string, opts ...Option) (*RuleSet, error) {
+	s := &RuleSet{}
+	for i, text := range rules {
+		rule := Rule{Index: i, Text: text}
+		pattern := text
+		if len(pattern) > 0 && pattern[0] == '!' {
+			rule.Exclude = true
+			pattern = pattern[1:]
+		}
+		p, err := Compile(pattern, opts...)
+		if err != nil {
+			return nil, err
+		}
+		rule.pattern = p
+		s.rules = append(s.rules, rule)
+	}
+	return s, nil
+}
+
+// MustCompileRules is like CompileRules but panics if a rule cannot be
+// parsed.
+func MustCompileRules(rules ...string) *RuleSet {
+	s, err := CompileRules(rules)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// WithPolicy sets how conflicting rules are resolved and returns s.
+func (s *RuleSet) WithPolicy(policy RulePolicy) *RuleSet {
+	s.policy = policy
+	return s
+}
+
+// Rules returns the rules in order.
+func (s *RuleSet) Rules() []Rule {
+	return s.rules
+}
+
+// Decide reports whether path is included together with the rule that
+// decided it, which is nil if no rule matched. Under ExcludeWins the deciding
+// rule is the first matching exclude rule or, if there is none, the first
+// matching include rule.
+func (s *RuleSet) Decide(path string) (bool, *Rule) {
+	if s.policy == ExcludeWins {
+		var include *Rule
+		for i := range s.rules {
+			rule := &s.rules[i]
+			if (rule.Exclude || include == nil) && rule.pattern.Match(path) {
+				if rule.Exclude {
+					return false, rule
+				}
+				include = rule
+			}
+		}
+		return include != nil, include
+	}
+
+	for i := len(s.rules) - 1; i >= 0; i-- {
+		rule := &s.rules[i]
+		if rule.pattern.Match(path) {
+			return !rule.Exclude, rule
+		}
+	}
+	return false, nil
+}
+
+// Match reports whether path is included.
+func (s *RuleSet) Match(path string) bool {
+	included, _ := s.Decide(path)
+	return included
+}
+
+// Matches reports whether path is included.
+func (s *RuleSet) Matches(path string) (bool, error) {
+	return s.Match(path), nil
+}
+
+// CouldMatchPrefix reports whether any path inside the directory dir could be
+// included. Only include rules are consulted.
+func (s *RuleSet) CouldMatchPrefix(dir string) bool {
+	for i := range s.rules {
+		if !s.rules[i].Exclude && s.rules[i].pattern.CouldMatchPrefix(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxDepth returns the largest number of path segments an included path can
+// have, or -1 if there is no limit.
+func (s *RuleSet) MaxDepth() int {
+	depth := 0
+	for i := range s.rules {
+		if s.rules[i].Exclude {
+			continue
+		}
+		d := s.rules[i].pattern.MaxDepth()
+		if d < 0 {
+			return -1
+		}
+		depth = max(depth, d)
+	}
+	return depth
+}
@@ -0,0 +1,239 @@
+package glob
+
+import (
+	"slices"
+	"strings"
+)
+
+// PatternSet matches a path against many patterns at once. Patterns are
+// grouped by shape so that most of them are never evaluated for a given path:
+// literal patterns are looked up in a map, patterns like "*.go" and "**/*.go"
+// in tables keyed by their suffix, and everything else is stored in a trie of
+// literal prefixes, so only patterns whose prefix the path starts with are
+// run. It is safe for concurrent use.
+type PatternSet struct {
+	patterns []*Pattern
+
+	exact map[string][]int
+
+	suffixLens []int
+	suffixes   map[string][]suffixEntry
+
+	prefixes prefixNode
+	negated  []int
+}
+
+// suffixEntry is a pattern of the form "*suffix", or "**/*suffix" when anyDir
+// is set.
+type suffixEntry struct {
+	index  int
+	anyDir bool
+}
+
+// prefixNode is a trie node. Its entries are the patterns whose literal
+// prefix ends here, together with the nodes that follow the prefix.
+type prefixNode struct {
+	children map[byte]*prefixNode
+	entries  []prefixEntry
+}
+
+type prefixEntry struct {
+	index int
+	rest  *node
+}
+
+// CompileSet compiles patterns into a PatternSet. Indices reported by the set
+// refer to positions in patterns. The first malformed pattern is reported as
+// a *SyntaxError.
+func CompileSet(patterns []string, opts ...Option) (*PatternSet, error) {
+	s := &PatternSet{
+		exact:    make(map[string][]int),
+		suffixes: make(map[string][]suffixEntry),
+	}
+	for i, pattern := range patterns {
+		p, err := Compile(pattern, opts...)
+		if err != nil {
+			return nil, err
+		}
+		s.add(i, p)
+	}
+	return s, nil
+}
+
+// MustCompileSet is like CompileSet but panics if a pattern cannot be parsed.
+func MustCompileSet(patterns ...string) *PatternSet {
+	s, err := CompileSet(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s *PatternSet) add(index int, p *Pattern) {
+	s.patterns = append(s.patterns, p)
+	if p.negate {
+		s.negated = append(s.negated, index)
+		return
+	}
+
+	prefix, rest := literalPrefix(p.ast)
+	if rest == nil {
+		s.exact[prefix] = append(s.exact[prefix], index)
+		return
+	}
+	if suffix, anyDir, ok := suffixPattern(p.ast); ok {
+		if !slices.Contains(s.suffixLens, len(suffix)) {
+			s.suffixLens = append(s.suffixLens, len(suffix))
+		}
+		s.suffixes[suffix] = append(s.suffixes[suffix], suffixEntry{index: index, anyDir: anyDir})
+		return
+	}
+
+	n := &s.prefixes
+	for i := 0; i < len(prefix); i++ {
+		child := n.children[prefix[i]]
+		if child == nil {
+			if n.children == nil {
+				n.children = make(map[byte]*prefixNode)
+			}
+			child = &prefixNode{}
+			n.children[prefix[i]] = child
+		}
+		n = child
+	}
+	n.entries = append(n.entries, prefixEntry{index: index, rest: rest})
+}
+
+// literalPrefix returns the text matched by the leading fixed nodes of n and
+// the first node after them.
+func literalPrefix(n *node) (string, *node) {
+	var sb strings.Builder
+	for ; n != nil; n = n.Next {
+		switch n.Type {
+		case tokenLiteral:
+			sb.WriteString(n.Value)
+		case tokenDot:
+			sb.WriteByte('.')
+		case tokenSlash:
+			sb.WriteByte('/')
+		default:
+			return sb.String(), n
+		}
+	}
+	return sb.String(), nil
+}
+
+// suffixPattern recognizes "*suffix" and "**/*suffix" where suffix is fixed
+// text without a slash.
+func suffixPattern(n *node) (string, bool, bool) {
+	anyDir := false
+	if n.Type == tokenDoubleStar && n.Next != nil && n.Next.Type == tokenSlash {
+		anyDir = true
+		n = n.Next.Next
+	}
+	if n == nil || n.Type != tokenStar {
+		return "", false, false
+	}
+	suffix, rest := literalPrefix(n.Next)
+	if rest != nil || suffix == "" || strings.Contains(suffix, "/") {
+		return "", false, false
+	}
+	return suffix, anyDir, true
+}
+
+// Len returns the number of patterns in the set.
+func (s *PatternSet) Len() int {
+	return len(s.patterns)
+}
+
+// Pattern returns the pattern at index i.
+func (s *PatternSet) Pattern(i int) *Pattern {
+	return s.patterns[i]
+}
+
+// Match reports whether path matches any pattern in the set.
+func (s *PatternSet) Match(path string) bool {
+	var buf [1]int
+	return len(s.appendMatches(buf[:0], path, true)) > 0
+}
+
+// MatchIndices returns the indices of the patterns that match path, in
+// increasing order.
+func (s *PatternSet) MatchIndices(path string) []int {
+	return s.AppendMatchIndices(nil, path)
+}
+
+// AppendMatchIndices is like MatchIndices but appends the indices to dst.
+func (s *PatternSet) AppendMatchIndices(dst []int, path string) []int {
+	start := len(dst)
+	dst = s.appendMatches(dst, path, false)
+	slices.Sort(dst[start:])
+	return dst
+}
+
+// CouldMatchPrefix reports whether any path inside the directory dir could
+// match a pattern in the set.
+func (s *PatternSet) CouldMatchPrefix(dir string) bool {
+	for _, p := range s.patterns {
+		if p.CouldMatchPrefix(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendMatches appends the indices of matching patterns to dst in no
+// particular order. If first is set it stops after the first match.
+func (s *PatternSet) appendMatches(dst []int, path string, first bool) []int {
+	if indices, ok := s.exact[path]; ok {
+		dst = append(dst, indices...)
+		if first {
+			return dst
+		}
+	}
+
+	if len(s.suffixLens) > 0 {
+		base := len(path) - strings.LastIndexByte(path, '/') - 1
+		for _, n := range s.suffixLens {
+			if n > base {
+				continue
+			}
+			for _, entry := range s.suffixes[path[len(path)-n:]] {
+				if entry.anyDir || base == len(path) {
+					dst = append(dst, entry.index)
+					if first {
+						return dst
+					}
+				}
+			}
+		}
+	}
+
+	n := &s.prefixes
+	for i := 0; ; i++ {
+		for _, entry := range n.entries {
+			if entry.rest.match(path, i, false) {
+				dst = append(dst, entry.index)
+				if first {
+					return dst
+				}
+			}
+		}
+		if i == len(path) {
+			break
+		}
+		if n = n.children[path[i]]; n == nil {
+			break
+		}
+	}
+
+	for _, index := range s.negated {
+		if s.patterns[index].Match(path) {
+			dst = append(dst, index)
+			if first {
+				return dst
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,140 @@
+package glob
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ErrSymlinkCycle is reported through the error policy when following a
+// symbolic link would re-enter a directory that is already being walked.
+var ErrSymlinkCycle = errors.New("glob: symlink cycle")
+
+var errUnresolvedSymlink = errors.New("glob: cannot resolve symlink")
+
+// maxLinkHops bounds how many links resolveLinks follows for one path, so
+// that links pointing at each other cannot loop forever.
+const maxLinkHops = 40
+
+// readLinkFS is implemented by file systems that can report symlink targets,
+// such as os.DirFS from Go 1.25.
+type readLinkFS interface {
+	fs.FS
+	ReadLink(name string) (string, error)
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// dirID identifies a directory independently of the path it was reached by.
+// Where the platform exposes device and inode numbers they are used;
+// otherwise the directory's path with all symlinks resolved is. Paths that
+// leave the file system are kept as they are, without resolving further.
+type dirID struct {
+	dev      uint64
+	ino      uint64
+	resolved string
+}
+
+// ancestor is a link in the chain of directories above the one being read.
+type ancestor struct {
+	id     dirID
+	parent *ancestor
+}
+
+func (a *ancestor) contains(id dirID) bool {
+	for current := a; current != nil; current = current.parent {
+		if current.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func isSymlink(entry fs.DirEntry) bool {
+	return entry != nil && entry.Type()&fs.ModeSymlink != 0
+}
+
+// isDir reports whether entry is a directory, following symbolic links when
+// the walk is configured to.
+func (w *walker) isDir(path string, entry fs.DirEntry) bool {
+	if entry.IsDir() {
+		return true
+	}
+	if !w.fm.followSymlinks || !isSymlink(entry) {
+		return false
+	}
+	info, err := fs.Stat(w.fsys, path)
+	return err == nil && info.IsDir()
+}
+
+// identify returns the identity of the directory job is about to read.
+func (w *walker) identify(job walkJob) (dirID, error) {
+	var info fs.FileInfo
+	var err error
+	if job.entry == nil || isSymlink(job.entry) {
+		info, err = fs.Stat(w.fsys, job.dir)
+	} else {
+		info, err = job.entry.Info()
+	}
+	if err != nil {
+		return dirID{}, err
+	}
+	if id, ok := fileID(info); ok {
+		return id, nil
+	}
+
+	if job.ancestors == nil {
+		return dirID{resolved: "."}, nil
+	}
+	parent := job.ancestors.id.resolved
+	if !isSymlink(job.entry) {
+		return dirID{resolved: joinPath(parent, job.entry.Name())}, nil
+	}
+
+	rl, ok := w.fsys.(readLinkFS)
+	if !ok {
+		return dirID{}, &fs.PathError{Op: "readlink", Path: job.dir, Err: errUnresolvedSymlink}
+	}
+	resolved, err := resolveLinks(rl, parent, job.entry.Name())
+	if err != nil {
+		return dirID{}, err
+	}
+	return dirID{resolved: resolved}, nil
+}
+
+// resolveLinks joins name to the link-free directory dir and resolves every
+// symbolic link along the way, including links whose targets contain more
+// links. It fails with ErrSymlinkCycle after maxLinkHops links.
+func resolveLinks(fsys readLinkFS, dir, name string) (string, error) {
+	resolved := dir
+	rest := []string{name}
+	for hops := 0; len(rest) > 0; {
+		next := path.Join(resolved, rest[0])
+		rest = rest[1:]
+		if !fs.ValidPath(next) {
+			return path.Join(append([]string{next}, rest...)...), nil
+		}
+
+		info, err := fsys.Lstat(next)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if hops++; hops > maxLinkHops {
+			return "", &fs.PathError{Op: "readlink", Path: path.Join(dir, name), Err: ErrSymlinkCycle}
+		}
+		target, err := fsys.ReadLink(next)
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			return path.Join(append([]string{target}, rest...)...), nil
+		}
+		rest = append(strings.Split(target, "/"), rest...)
+	}
+	return resolved, nil
+}
@@ -10,16 +10,22 @@ const (
 	tokenSlash
 	tokenDot
 	tokenNegate
+	tokenQuestion
+	tokenClass
+	tokenBraceOpen
+	tokenBraceClose
+	tokenComma
 )
 
 type token struct {
 	Type    tokenType
 	Literal string
+	Pos     int
 }
 
 func isSpecialChar(ch byte) bool {
 	switch ch {
-	case '*', '/', '!', '.':
+	case '*', '/', '.', '?', '[', '{', '}', ',', '\\':
 		return true
 	default:
 		return false
@@ -0,0 +1,224 @@
+package glob
+
+import (
+	"cmp"
+	"context"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// walker holds the state of a single walk.
+type walker struct {
+	fm   *FSPattern
+	ctx  context.Context
+	fsys fs.FS
+	fn   WalkFunc
+
+	// maxDepth is the smaller of the configured limit and the pattern's own
+	// depth, or zero if neither applies.
+	maxDepth int
+
+	// errs collects directory errors under the CollectErrors policy. It is
+	// only touched by the goroutine coordinating the walk.
+	errs []error
+}
+
+// walkJob is a directory to read. The excluder and ancestors are those of
+// the directory's parent; open derives the directory's own.
+type walkJob struct {
+	dir       string
+	depth     int
+	entry     fs.DirEntry
+	excluder  Excluder
+	ancestors *ancestor
+}
+
+func (w *walker) run() error {
+	root := walkJob{dir: ".", excluder: w.fm.excluder}
+	if w.fm.workers > 1 {
+		return w.parallel(root)
+	}
+	return w.serial(root)
+}
+
+// dirError applies the error hook or policy to a failure to read dir. A nil
+// result means the directory is skipped and the walk continues.
+func (w *walker) dirError(dir string, err error) error {
+	if w.fm.onError != nil {
+		return w.fm.onError(dir, err)
+	}
+	switch w.fm.policy {
+	case SkipOnError:
+		return nil
+	case CollectErrors:
+		w.errs = append(w.errs, err)
+		return nil
+	default:
+		return err
+	}
+}
+
+// open reads the directory of job and returns its entries together with the
+// excluder and ancestor chain that apply inside it.
+func (w *walker) open(job walkJob) ([]fs.DirEntry, Excluder, *ancestor, error) {
+	ancestors := job.ancestors
+	if w.fm.followSymlinks {
+		id, err := w.identify(job)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if ancestors.contains(id) {
+			return nil, nil, nil, &fs.PathError{Op: "walk", Path: job.dir, Err: ErrSymlinkCycle}
+		}
+		ancestors = &ancestor{id: id, parent: ancestors}
+	}
+
+	excluder := job.excluder
+	if excluder != nil {
+		var err error
+		excluder, err = excluder.Enter(w.fsys, job.dir)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	entries, err := fs.ReadDir(w.fsys, job.dir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return entries, excluder, ancestors, nil
+}
+
+// visit decides whether the entry at path, depth levels below the root, is
+// reported and whether the walk descends into it.
+func (w *walker) visit(path string, depth int, entry fs.DirEntry, excluder Excluder) (bool, bool, error) {
+	isDir := w.isDir(path, entry)
+	if excluder != nil && excluder.Excluded(path, isDir) {
+		return false, false, nil
+	}
+
+	matches := false
+	if depth >= w.fm.minDepth {
+		var err error
+		matches, err = w.fm.Matches(path)
+		if err == nil && matches {
+			matches, err = w.fm.filter.accept(entry)
+		}
+		if err != nil {
+			return false, false, err
+		}
+	}
+
+	descend := isDir && (w.maxDepth <= 0 || depth < w.maxDepth) && w.fm.couldMatchPrefix(path)
+	return matches, descend, nil
+}
+
+func joinPath(dir, name string) string {
+	if dir == "." {
+		return name
+	}
+	return dir + "/" + name
+}
+
+func (w *walker) serial(job walkJob) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+
+	entries, excluder, ancestors, err := w.open(job)
+	if err != nil {
+		return w.dirError(job.dir, err)
+	}
+
+	for _, entry := range entries {
+		path := joinPath(job.dir, entry.Name())
+
+		matches, descend, err := w.visit(path, job.depth+1, entry, excluder)
+		if err != nil {
+			return err
+		}
+
+		if matches {
+			if err := w.fn(path, entry); err != nil {
+				if err != fs.SkipDir {
+					return err
+				}
+				if !entry.IsDir() {
+					return nil
+				}
+				descend = false
+			}
+		}
+
+		if descend {
+			child := walkJob{dir: path, depth: job.depth + 1, entry: entry, excluder: excluder, ancestors: ancestors}
+			if err := w.serial(child); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// deliverSorted reports buffered matches in the order a serial walk would.
+// Skips cannot prune a walk that has already finished, so they are applied by
+// dropping later matches below the skipped directory.
+func deliverSorted(ctx context.Context, matches []walkMatch, fn WalkFunc) error {
+	slices.SortFunc(matches, func(a, b walkMatch) int {
+		return comparePaths(a.path, b.path)
+	})
+
+	var skipped []string
+	for _, match := range matches {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if isSkipped(match.path, skipped) {
+			continue
+		}
+		if err := fn(match.path, match.entry); err != nil {
+			switch {
+			case err != fs.SkipDir:
+				return err
+			case match.entry.IsDir():
+				skipped = append(skipped, match.path+"/")
+			case path.Dir(match.path) == ".":
+				return nil
+			default:
+				skipped = append(skipped, path.Dir(match.path)+"/")
+			}
+		}
+	}
+	return nil
+}
+
+// comparePaths orders paths segment by segment, which is the depth-first
+// order of a serial walk: "d/a/x" comes before "d/a.txt" because the segment
+// "a" sorts before "a.txt", even though '.' sorts before '/'.
+func comparePaths(a, b string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		if a[i] == '/' {
+			return -1
+		}
+		if b[i] == '/' {
+			return 1
+		}
+		return cmp.Compare(a[i], b[i])
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+func isSkipped(p string, skipped []string) bool {
+	for _, prefix := range skipped {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}